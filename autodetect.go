@@ -0,0 +1,115 @@
+package modernmt
+
+import (
+	"errors"
+	"strings"
+)
+
+func (re *ModernMT) TranslateAuto(target string, segments []string, options *TranslateOptions) ([]Translation, error) {
+	translations := make([]Translation, len(segments))
+	if len(segments) == 0 {
+		return translations, nil
+	}
+
+	format := ""
+	if options != nil {
+		format = options.Format
+	}
+
+	var detected []DetectedLanguage
+	for _, chunk := range chunkSegments(segments) {
+		res, err := re.DetectLanguages(chunk, format)
+		if err != nil {
+			return nil, err
+		}
+		detected = append(detected, res...)
+	}
+
+	if len(detected) != len(segments) {
+		return nil, errors.New("language detection returned a wrong number of results")
+	}
+
+	// segments grouped by detected language, in order of first appearance
+	var languages []string
+	groups := map[string][]int{}
+
+	for i, segment := range segments {
+		language := detected[i].DetectedLanguage
+
+		if language != "" && isTargetLanguage(language, target) {
+			translations[i] = Translation{
+				Translation:      segment,
+				DetectedLanguage: language,
+			}
+			continue
+		}
+
+		// segments whose language could not be detected are left to the API auto-detection
+		if _, ok := groups[language]; !ok {
+			languages = append(languages, language)
+		}
+		groups[language] = append(groups[language], i)
+	}
+
+	for _, language := range languages {
+		indexes := groups[language]
+
+		q := make([]string, len(indexes))
+		for i, index := range indexes {
+			q[i] = segments[index]
+		}
+
+		res, err := re.translateChunked(TranslateRequest{
+			Source:   Language(language),
+			Target:   Language(target),
+			Segments: q,
+			Options:  options,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i, index := range indexes {
+			translation := res[i]
+			if translation.DetectedLanguage == "" {
+				translation.DetectedLanguage = language
+			}
+			translations[index] = translation
+		}
+	}
+
+	return translations, nil
+}
+
+// a detected language matches the target if the base languages are the same and their scripts and regions do
+// not conflict: detection returns base tags, so en is already in en-US, but zh-TW is not in zh-CN, nor sr-Latn
+// in sr-Cyrl
+func isTargetLanguage(detected string, target string) bool {
+	_detected, err := ParseLanguage(detected)
+	if err != nil {
+		return strings.EqualFold(detected, target)
+	}
+	_target, err := ParseLanguage(target)
+	if err != nil {
+		return strings.EqualFold(detected, target)
+	}
+
+	if _detected.Base() != _target.Base() {
+		return false
+	}
+
+	return compatibleSubtags(_detected.Script(), _target.Script()) &&
+		compatibleSubtags(_detected.Region(), _target.Region())
+}
+
+func compatibleSubtags(a string, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+func languageBase(language string) string {
+	language = strings.ToLower(language)
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		return language[:i]
+	}
+	return language
+}
@@ -0,0 +1,28 @@
+package modernmt
+
+import "testing"
+
+func TestIsTargetLanguage(t *testing.T) {
+	tests := []struct {
+		detected, target string
+		want             bool
+	}{
+		{"en", "en", true},
+		{"pt-BR", "pt_br", true},
+		{"en-US", "en", true},
+		{"en", "en-US", true},
+		{"pt", "pt-BR", true},
+		{"zh-Hant", "zh-Hant-TW", true},
+		{"zh-Hans", "zh-Hant-TW", false},
+		{"zh-TW", "zh-CN", false},
+		{"pt-PT", "pt-BR", false},
+		{"sr-Latn", "sr-Cyrl", false},
+		{"it", "en", false},
+	}
+
+	for _, test := range tests {
+		if got := isTargetLanguage(test.detected, test.target); got != test.want {
+			t.Errorf("isTargetLanguage(%q, %q) = %v, want %v", test.detected, test.target, got, test.want)
+		}
+	}
+}
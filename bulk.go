@@ -0,0 +1,175 @@
+package modernmt
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBulkThreshold         = 100
+	defaultBulkConcurrency       = 4
+	defaultBulkRequestsPerSecond = 10
+)
+
+type BulkAddOptions struct {
+	// above this number of units, they are packed into a single TMX import
+	Threshold int
+
+	Concurrency       int
+	RequestsPerSecond float64
+}
+
+type BulkAddError struct {
+	Index int
+	Err   error
+}
+
+type BulkImportJob struct {
+	Jobs   []ImportJob
+	Errors []BulkAddError
+}
+
+func (re BulkImportJob) Size() int {
+	size := 0
+	for _, job := range re.Jobs {
+		size += job.Size
+	}
+	return size
+}
+
+// progress of all jobs, weighted by their size
+func (re BulkImportJob) Progress() float32 {
+	if len(re.Jobs) == 0 {
+		return 1
+	}
+
+	var done, total float32
+	for _, job := range re.Jobs {
+		size := float32(job.Size)
+		if size == 0 {
+			size = 1
+		}
+		done += job.Progress * size
+		total += size
+	}
+
+	return done / total
+}
+
+func (re BulkImportJob) Completed() bool {
+	for _, job := range re.Jobs {
+		if job.Progress < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (re *memoryServices) AddBulk(id int64, units []TranslationUnit, options *BulkAddOptions) (BulkImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.AddBulkByKey(_id, units, options)
+}
+
+func (re *memoryServices) AddBulkByKey(id string, units []TranslationUnit,
+	options *BulkAddOptions) (BulkImportJob, error) {
+
+	if len(units) == 0 {
+		return BulkImportJob{}, errors.New("no translation units to add")
+	}
+
+	threshold := defaultBulkThreshold
+	concurrency := defaultBulkConcurrency
+	rps := float64(defaultBulkRequestsPerSecond)
+	if options != nil {
+		if options.Threshold > 0 {
+			threshold = options.Threshold
+		}
+		if options.Concurrency > 0 {
+			concurrency = options.Concurrency
+		}
+		if options.RequestsPerSecond > 0 {
+			rps = options.RequestsPerSecond
+		}
+	}
+
+	if len(units) > threshold {
+		job, err := re.ImportTranslationUnitsByKey(id, units)
+		if err != nil {
+			return BulkImportJob{}, err
+		}
+		return BulkImportJob{Jobs: []ImportJob{job}}, nil
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+	defer ticker.Stop()
+
+	jobs := make([]*ImportJob, len(units))
+	var errs []BulkAddError
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	indexes := make(chan int)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				unit := units[i]
+				job, err := re.AddByKey(id, unit.Source, unit.Target, unit.Sentence, unit.Translation, unit.Tuid)
+
+				mutex.Lock()
+				if err != nil {
+					errs = append(errs, BulkAddError{Index: i, Err: err})
+				} else {
+					jobs[i] = &job
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for i := range units {
+		<-ticker.C
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	bulk := BulkImportJob{Errors: errs}
+	for _, job := range jobs {
+		if job != nil {
+			bulk.Jobs = append(bulk.Jobs, *job)
+		}
+	}
+
+	if len(bulk.Jobs) == 0 {
+		return bulk, errs[0].Err
+	}
+
+	return bulk, nil
+}
+
+func (re *memoryServices) GetBulkImportStatus(bulk BulkImportJob) (BulkImportJob, error) {
+	res := BulkImportJob{
+		Jobs:   make([]ImportJob, len(bulk.Jobs)),
+		Errors: bulk.Errors,
+	}
+
+	for i, job := range bulk.Jobs {
+		if job.Progress >= 1 {
+			res.Jobs[i] = job
+			continue
+		}
+
+		status, err := re.GetImportStatus(job.Id)
+		if err != nil {
+			return BulkImportJob{}, err
+		}
+		res.Jobs[i] = status
+	}
+
+	return res, nil
+}
@@ -0,0 +1,214 @@
+package modernmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextVectorCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*contextVectorCacheEntry
+
+	// the keys by which each memory id has been written, e.g. its external id
+	aliases map[int64]map[string]bool
+}
+
+type contextVectorCacheEntry struct {
+	vectors map[string]ContextVector
+	hints   []string
+	created time.Time
+}
+
+func (re *ModernMT) EnableContextVectorCache(size int, ttl time.Duration) {
+	re.contextVectors.configure(size, ttl)
+}
+
+func (re *contextVectorCache) configure(size int, ttl time.Duration) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	re.size = size
+	re.ttl = ttl
+	re.entries = map[string]*contextVectorCacheEntry{}
+}
+
+func (re *contextVectorCache) enabled() bool {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	return re.size > 0
+}
+
+func (re *contextVectorCache) get(key string) (map[string]ContextVector, bool) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	entry, ok := re.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if re.ttl > 0 && time.Since(entry.created) > re.ttl {
+		delete(re.entries, key)
+		return nil, false
+	}
+
+	return copyContextVectors(entry.vectors), true
+}
+
+func (re *contextVectorCache) put(key string, hints []string, vectors map[string]ContextVector) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	if re.size <= 0 {
+		return
+	}
+
+	// evict the oldest entry when full
+	if _, ok := re.entries[key]; !ok && len(re.entries) >= re.size {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range re.entries {
+			if oldestKey == "" || entry.created.Before(oldest) {
+				oldestKey = k
+				oldest = entry.created
+			}
+		}
+		delete(re.entries, oldestKey)
+	}
+
+	re.entries[key] = &contextVectorCacheEntry{
+		vectors: copyContextVectors(vectors),
+		hints:   hints,
+		created: time.Now(),
+	}
+}
+
+// drops every entry that may depend on the given memory, vectors computed without hints depend on all memories.
+// The memory is identified by the key used for the write and by its id, so that hints referring to it by
+// any key seen so far are matched. Aliases are only learned from writes, so external keys never seen in a
+// write may name any memory and always match, and a write to an unknown external key drops every entry. Writes are asynchronous: entries computed before the import job
+// completes are dropped again when GetImportStatus reports its completion
+func (re *contextVectorCache) invalidate(key string, id int64) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	if re.size <= 0 {
+		return
+	}
+
+	if re.aliases == nil {
+		re.aliases = map[int64]map[string]bool{}
+	}
+	if id == 0 {
+		id = re.resolve(key)
+	}
+	if id == 0 {
+		// any memory may have been written
+		re.entries = map[string]*contextVectorCacheEntry{}
+		return
+	}
+	if key != "" {
+		if re.aliases[id] == nil {
+			re.aliases[id] = map[string]bool{}
+		}
+		re.aliases[id][key] = true
+	}
+
+	keys := map[string]bool{strconv.FormatInt(id, 10): true}
+	for alias := range re.aliases[id] {
+		keys[alias] = true
+	}
+
+	known := map[string]bool{}
+	for _, aliases := range re.aliases {
+		for alias := range aliases {
+			known[alias] = true
+		}
+	}
+
+	for k, entry := range re.entries {
+		if len(entry.hints) == 0 {
+			delete(re.entries, k)
+			continue
+		}
+
+		for _, hint := range entry.hints {
+			if keys[hint] || (!isDigit(hint) && !known[hint]) {
+				delete(re.entries, k)
+				break
+			}
+		}
+	}
+}
+
+// returns the id of a memory key, or 0 if it is an external key never seen in a write
+func (re *contextVectorCache) resolve(key string) int64 {
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return id
+	}
+
+	for id, aliases := range re.aliases {
+		if aliases[key] {
+			return id
+		}
+	}
+
+	return 0
+}
+
+func contextVectorCacheKey(digest []byte, source string, targets []string, hints []string, limit int,
+	compression string) string {
+
+	_targets := append([]string(nil), targets...)
+	sort.Strings(_targets)
+	_hints := append([]string(nil), hints...)
+	sort.Strings(_hints)
+
+	return strings.Join([]string{
+		hex.EncodeToString(digest),
+		source,
+		strings.Join(_targets, ","),
+		strings.Join(_hints, ","),
+		strconv.Itoa(limit),
+		compression,
+	}, "|")
+}
+
+// hashes the file content from the current position and rewinds it, so that it can still be uploaded
+func fileDigest(file *os.File) ([]byte, error) {
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+func copyContextVectors(vectors map[string]ContextVector) map[string]ContextVector {
+	res := make(map[string]ContextVector, len(vectors))
+	for target, vector := range vectors {
+		res[target] = append(ContextVector(nil), vector...)
+	}
+	return res
+}
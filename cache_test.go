@@ -0,0 +1,170 @@
+package modernmt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakes the context vector, memory content and import job endpoints, counting the context vector requests
+func newContextVectorServer(t *testing.T) (*ModernMT, func() int) {
+	var mutex sync.Mutex
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data interface{}
+		switch {
+		case r.URL.Path == "/context-vector":
+			mutex.Lock()
+			requests++
+			mutex.Unlock()
+			data = map[string]interface{}{"source": "en", "vectors": map[string]interface{}{"it": "1:0.5"}}
+		case strings.HasSuffix(r.URL.Path, "/content"):
+			// writes by external key report the memory id, 7, in the job
+			data = map[string]interface{}{"id": "job", "memory": 7, "size": 1, "progress": 0}
+		case r.URL.Path == "/import-jobs/job":
+			data = map[string]interface{}{"id": "job", "memory": 7, "size": 1, "progress": 1}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	mmt := Create("api-key")
+	mmt.SetBaseUrl(server.URL)
+	mmt.EnableContextVectorCache(10, time.Hour)
+
+	return mmt, func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return requests
+	}
+}
+
+func TestContextVectorCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		hints []string
+		write func(mmt *ModernMT) error
+		hit   bool
+	}{
+		{"write to another memory", []string{"1"}, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.Add(7, "en", "it", "a", "b", "")
+			return err
+		}, true},
+		{"write by id to a hinted memory", []string{"7"}, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.Add(7, "en", "it", "a", "b", "")
+			return err
+		}, false},
+		{"write by key to a memory hinted by id", []string{"7"}, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.AddByKey("x:doc", "en", "it", "a", "b", "")
+			return err
+		}, false},
+		{"write by id to a memory hinted by an unseen key", []string{"x:doc"}, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.Add(7, "en", "it", "a", "b", "")
+			return err
+		}, false},
+		{"no hints", nil, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.Add(1, "en", "it", "a", "b", "")
+			return err
+		}, false},
+		{"import job completion", []string{"7"}, func(mmt *ModernMT) error {
+			_, err := mmt.Memories.GetImportStatus("job")
+			return err
+		}, false},
+	}
+
+	for _, test := range tests {
+		mmt, requests := newContextVectorServer(t)
+
+		for i := 0; i < 2; i++ {
+			if _, err := mmt.GetContextVectorsByKeys("en", []string{"it"}, "text", test.hints, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if requests() != 1 {
+			t.Errorf("%s: a cache hit sent %d requests, want 1", test.name, requests())
+		}
+
+		if err := test.write(mmt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mmt.GetContextVectorsByKeys("en", []string{"it"}, "text", test.hints, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if hit := requests() == 1; hit != test.hit {
+			t.Errorf("%s: cache hit = %v after the write, want %v", test.name, hit, test.hit)
+		}
+	}
+}
+
+func TestContextVectorCacheLearnsAliases(t *testing.T) {
+	mmt, requests := newContextVectorServer(t)
+
+	// once a write maps x:doc to memory 7, a write to another memory no longer drops vectors hinted by x:doc
+	if _, err := mmt.Memories.AddByKey("x:doc", "en", "it", "a", "b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mmt.GetContextVectorsByKeys("en", []string{"it"}, "text", []string{"x:doc"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mmt.contextVectors.invalidate("3", 3)
+	if _, err := mmt.GetContextVectorsByKeys("en", []string{"it"}, "text", []string{"x:doc"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 1 {
+		t.Errorf("write to another memory invalidated the entry: %d requests, want 1", requests())
+	}
+
+	if _, err := mmt.Memories.Add(7, "en", "it", "a", "b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mmt.GetContextVectorsByKeys("en", []string{"it"}, "text", []string{"x:doc"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 2 {
+		t.Errorf("write by id to the aliased memory kept the entry: %d requests, want 2", requests())
+	}
+}
+
+func TestContextVectorCacheSkipsUpload(t *testing.T) {
+	mmt, requests := newContextVectorServer(t)
+
+	path := filepath.Join(t.TempDir(), "document.txt")
+	if err := os.WriteFile(path, []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		vectors, err := mmt.GetContextVectorsFromFilePathByKeys("en", []string{"it"}, path, []string{"7"}, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vectors["it"].String() != "1:0.5" {
+			t.Errorf("vectors = %v", vectors)
+		}
+	}
+	if requests() != 1 {
+		t.Errorf("a cache hit uploaded the file: %d requests, want 1", requests())
+	}
+
+	if _, err := mmt.Memories.Add(7, "en", "it", "a", "b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mmt.GetContextVectorsFromFilePathByKeys("en", []string{"it"}, path, []string{"7"}, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if requests() != 2 {
+		t.Errorf("write to the hinted memory kept the entry: %d requests, want 2", requests())
+	}
+}
@@ -0,0 +1,152 @@
+package modernmt
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"sync"
+	"time"
+)
+
+const defaultReplayWindow = 24 * time.Hour
+
+// header of the webhook request carrying the callback signature
+const CallbackSignatureHeader = "x-modernmt-signature"
+
+var ErrDuplicateCallback = errors.New("duplicate callback")
+
+// remembers callback ids until they expire; ids are queued in insertion order, so expired ones are dropped
+// from the front of the queue without scanning the whole cache
+type replayCache struct {
+	mutex sync.Mutex
+	seen  map[string]time.Time
+	queue []replayEntry
+}
+
+type replayEntry struct {
+	id         string
+	expiration time.Time
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{
+		seen: map[string]time.Time{},
+	}
+}
+
+// returns false if the id has already been seen and has not expired yet
+func (re *replayCache) add(id string, expiration time.Time) bool {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	now := time.Now()
+	for len(re.queue) > 0 && re.queue[0].expiration.Before(now) {
+		entry := re.queue[0]
+		re.queue = re.queue[1:]
+		if exp, ok := re.seen[entry.id]; ok && exp.Equal(entry.expiration) {
+			delete(re.seen, entry.id)
+		}
+	}
+
+	if exp, ok := re.seen[id]; ok && !exp.Before(now) {
+		return false
+	}
+
+	re.seen[id] = expiration
+	re.queue = append(re.queue, replayEntry{id: id, expiration: expiration})
+	return true
+}
+
+// forgets an id, so that the callback is accepted again; its queue entry is dropped once expired
+func (re *replayCache) remove(id string) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	delete(re.seen, id)
+}
+
+// enables the stricter, opt-in claim checks; replayed callbacks are always rejected
+func (re *ModernMT) SetCallbackOptions(options CallbackOptions) {
+	re.callbackOptions = &options
+}
+
+func (re *CallbackOptions) parserOptions() []jwt.ParserOption {
+	var options []jwt.ParserOption
+
+	if re.Issuer != "" {
+		options = append(options, jwt.WithIssuer(re.Issuer))
+	}
+	if re.Leeway != 0 {
+		options = append(options, jwt.WithLeeway(re.Leeway))
+	}
+	if re.MaxAge != 0 {
+		options = append(options, jwt.WithIssuedAt())
+	}
+	if re.RequireExpiration {
+		options = append(options, jwt.WithExpirationRequired())
+	}
+
+	return options
+}
+
+func (re *ModernMT) validateCallbackClaims(body []byte, claims jwt.MapClaims) error {
+	options := re.callbackOptions
+
+	if options.MaxAge != 0 {
+		iat, err := claims.GetIssuedAt()
+		if err != nil {
+			return err
+		}
+		if iat == nil {
+			return errors.New("callback token has no issued-at claim")
+		}
+		if time.Since(iat.Time) > options.MaxAge+options.Leeway {
+			return fmt.Errorf("callback token is older than %v", options.MaxAge)
+		}
+	}
+
+	if options.BodyHashClaim != "" {
+		expected, ok := claims[options.BodyHashClaim].(string)
+		if !ok {
+			return fmt.Errorf("callback token has no %s claim", options.BodyHashClaim)
+		}
+		if !matchesBodyHash(body, expected) {
+			return errors.New("callback body does not match the signed digest")
+		}
+	}
+
+	return nil
+}
+
+// rejects a callback already handled within the replay window and returns its id, so that it can be forgotten
+// if handling fails; the id is the jti or, since the token is not bound to the body, the token and body digests
+func (re *ModernMT) checkCallbackReplay(body []byte, signature string, claims jwt.MapClaims) (string, error) {
+	id, ok := claims["jti"].(string)
+	if !ok || id == "" {
+		bodyDigest := sha256.Sum256(body)
+		digest := sha256.Sum256(append([]byte(signature+"\x00"), bodyDigest[:]...))
+		id = hex.EncodeToString(digest[:])
+	}
+
+	window := defaultReplayWindow
+	if re.callbackOptions != nil && re.callbackOptions.ReplayWindow != 0 {
+		window = re.callbackOptions.ReplayWindow
+	}
+
+	if !re.seenCallbacks.add(id, time.Now().Add(window)) {
+		return "", fmt.Errorf("%w: %s", ErrDuplicateCallback, id)
+	}
+
+	return id, nil
+}
+
+func matchesBodyHash(body []byte, expected string) bool {
+	digest := sha256.Sum256(body)
+
+	return expected == hex.EncodeToString(digest[:]) ||
+		expected == base64.StdEncoding.EncodeToString(digest[:]) ||
+		expected == base64.RawURLEncoding.EncodeToString(digest[:])
+}
@@ -0,0 +1,98 @@
+package modernmt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+func newCallbackSigner(t *testing.T, mmt *ModernMT) func(claims jwt.MapClaims) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mmt.SetCallbackPublicKey(&key.PublicKey)
+
+	return func(claims jwt.MapClaims) string {
+		signature, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func callbackBody(translation string) []byte {
+	return []byte(`{"result":{"status":200,"data":[{"translation":"` + translation + `","characters":1,"billedCharacters":1}]}}`)
+}
+
+func TestCallbackReplay(t *testing.T) {
+	mmt := Create("api-key")
+	sign := newCallbackSigner(t, mmt)
+
+	// tokens without jti issued in the same second are identical, the body tells the callbacks apart
+	iat := time.Now().Unix()
+	first, second := sign(jwt.MapClaims{"iat": iat}), sign(jwt.MapClaims{"iat": iat})
+
+	if _, err := mmt.HandleTranslateCallback(callbackBody("a"), first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mmt.HandleTranslateCallback(callbackBody("b"), second); err != nil {
+		t.Errorf("callback with the same token and another body: %v", err)
+	}
+	if _, err := mmt.HandleTranslateCallback(callbackBody("a"), first); !errors.Is(err, ErrDuplicateCallback) {
+		t.Errorf("replayed callback: got %v, want ErrDuplicateCallback", err)
+	}
+
+	// the jti identifies the callback whatever the body
+	withId := sign(jwt.MapClaims{"jti": "1"})
+	if _, err := mmt.HandleTranslateCallback(callbackBody("c"), withId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mmt.HandleTranslateCallback(callbackBody("d"), withId); !errors.Is(err, ErrDuplicateCallback) {
+		t.Errorf("replayed jti: got %v, want ErrDuplicateCallback", err)
+	}
+}
+
+func TestCallbackRedeliveryAfterFailure(t *testing.T) {
+	mmt := Create("api-key")
+	sign := newCallbackSigner(t, mmt)
+	signature := sign(jwt.MapClaims{"jti": "1"})
+
+	var metadata struct{ Id int }
+	body := []byte(`{"metadata":{"Id":"x"},"result":{"status":200,"data":[]}}`)
+	if _, err := mmt.HandleTranslateListCallbackWithMetadata(body, signature, &metadata); err == nil {
+		t.Fatal("invalid metadata was accepted")
+	}
+
+	body = []byte(`{"metadata":{"Id":1},"result":{"status":200,"data":[]}}`)
+	if _, err := mmt.HandleTranslateListCallbackWithMetadata(body, signature, &metadata); err != nil {
+		t.Errorf("redelivered callback after a failure: %v", err)
+	}
+	if metadata.Id != 1 {
+		t.Errorf("metadata = %+v", metadata)
+	}
+}
+
+func TestReplayCacheExpiration(t *testing.T) {
+	cache := newReplayCache()
+	past := time.Now().Add(-time.Second)
+
+	if !cache.add("a", past) || !cache.add("a", time.Now().Add(time.Hour)) {
+		t.Error("expired id was rejected")
+	}
+	if cache.add("a", time.Now().Add(time.Hour)) {
+		t.Error("id was accepted twice")
+	}
+	if len(cache.queue) != 1 || len(cache.seen) != 1 {
+		t.Errorf("expired entries were not dropped: queue %d, seen %d", len(cache.queue), len(cache.seen))
+	}
+
+	cache.remove("a")
+	if !cache.add("a", time.Now().Add(time.Hour)) {
+		t.Error("removed id was rejected")
+	}
+}
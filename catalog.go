@@ -0,0 +1,240 @@
+package modernmt
+
+import (
+	_ "embed"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLanguageCatalogTTL = time.Hour
+
+	// delay before retrying a failed refresh, while the previous list is still served
+	languageCatalogRetryDelay = time.Minute
+)
+
+// snapshot of the supported languages, for tests and air-gapped builds
+//
+//go:embed languages.txt
+var languagesSnapshot string
+
+type LanguageCatalog struct {
+	mutex     sync.Mutex
+	fetch     func() ([]string, error)
+	ttl       time.Duration
+	languages map[Language]bool
+	fetched   time.Time
+	retryAt   time.Time
+}
+
+func NewLanguageCatalog(languages []string) *LanguageCatalog {
+	catalog := &LanguageCatalog{}
+	catalog.languages = catalog.parse(languages)
+	return catalog
+}
+
+func OfflineLanguageCatalog() *LanguageCatalog {
+	return NewLanguageCatalog(strings.Fields(languagesSnapshot))
+}
+
+// a non-positive ttl restores the default of one hour
+func (re *LanguageCatalog) SetTTL(ttl time.Duration) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	if ttl <= 0 {
+		ttl = defaultLanguageCatalogTTL
+	}
+	re.ttl = ttl
+}
+
+func (re *LanguageCatalog) Refresh() error {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	return re.refresh()
+}
+
+func (re *LanguageCatalog) refresh() error {
+	if re.fetch == nil {
+		return nil
+	}
+
+	list, err := re.fetch()
+	if err != nil {
+		return err
+	}
+
+	re.languages = re.parse(list)
+	re.fetched = time.Now()
+	return nil
+}
+
+func (re *LanguageCatalog) parse(list []string) map[Language]bool {
+	languages := map[Language]bool{}
+	for _, el := range list {
+		if language, err := ParseLanguage(el); err == nil {
+			languages[language] = true
+		}
+	}
+	return languages
+}
+
+func (re *LanguageCatalog) supported() (map[Language]bool, error) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	now := time.Now()
+	if re.fetch != nil && (re.languages == nil || now.Sub(re.fetched) > re.ttl && now.After(re.retryAt)) {
+		err := re.refresh()
+		if err != nil {
+			if re.languages == nil {
+				return nil, err
+			}
+
+			// if a previous version of the list is available, ignore API exception and retry later
+			delay := languageCatalogRetryDelay
+			if re.ttl < delay {
+				delay = re.ttl
+			}
+			re.retryAt = now.Add(delay)
+		}
+	}
+
+	return re.languages, nil
+}
+
+func (re *LanguageCatalog) Languages() ([]Language, error) {
+	supported, err := re.supported()
+	if err != nil {
+		return nil, err
+	}
+
+	languages := make([]Language, 0, len(supported))
+	for language := range supported {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i] < languages[j]
+	})
+
+	return languages, nil
+}
+
+func (re *LanguageCatalog) Contains(tag string) (bool, error) {
+	language, err := ParseLanguage(tag)
+	if err != nil {
+		return false, nil
+	}
+
+	supported, err := re.supported()
+	if err != nil {
+		return false, err
+	}
+
+	return supported[language], nil
+}
+
+func (re *LanguageCatalog) IsSupported(source string, target string) (bool, error) {
+	ok, err := re.Contains(source)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return re.Contains(target)
+}
+
+// lists the regional and script variants of a base language, such as pt-BR and pt-PT for pt
+func (re *LanguageCatalog) Variants(base string) ([]Language, error) {
+	languages, err := re.Languages()
+	if err != nil {
+		return nil, err
+	}
+
+	base = languageBase(base)
+
+	var variants []Language
+	for _, language := range languages {
+		if language.Base() == base && string(language) != base {
+			variants = append(variants, language)
+		}
+	}
+
+	return variants, nil
+}
+
+func (re *LanguageCatalog) DisplayName(tag string) string {
+	language, err := ParseLanguage(tag)
+	if err != nil {
+		return tag
+	}
+
+	name, ok := languageNames[language.Base()]
+	if !ok {
+		return string(language)
+	}
+
+	var qualifiers []string
+	if script := language.Script(); script != "" {
+		if scriptName, ok := scriptNames[script]; ok {
+			qualifiers = append(qualifiers, scriptName)
+		} else {
+			qualifiers = append(qualifiers, script)
+		}
+	}
+	if region := language.Region(); region != "" {
+		if regionName, ok := regionNames[region]; ok {
+			qualifiers = append(qualifiers, regionName)
+		} else {
+			qualifiers = append(qualifiers, region)
+		}
+	}
+
+	if len(qualifiers) > 0 {
+		name += " (" + strings.Join(qualifiers, ", ") + ")"
+	}
+
+	return name
+}
+
+var languageNames = map[string]string{
+	"af": "Afrikaans", "am": "Amharic", "ar": "Arabic", "as": "Assamese", "az": "Azerbaijani",
+	"ba": "Bashkir", "be": "Belarusian", "bg": "Bulgarian", "bho": "Bhojpuri", "bm": "Bambara",
+	"bn": "Bengali", "bo": "Tibetan", "bs": "Bosnian", "ca": "Catalan", "ceb": "Cebuano",
+	"cs": "Czech", "cy": "Welsh", "da": "Danish", "de": "German", "dz": "Dzongkha",
+	"el": "Greek", "en": "English", "eo": "Esperanto", "es": "Spanish", "et": "Estonian",
+	"eu": "Basque", "fa": "Persian", "fi": "Finnish", "fj": "Fijian", "fo": "Faroese",
+	"fr": "French", "fur": "Friulian", "ga": "Irish", "gd": "Scottish Gaelic", "gl": "Galician",
+	"gn": "Guarani", "gu": "Gujarati", "ha": "Hausa", "he": "Hebrew", "hi": "Hindi",
+	"hr": "Croatian", "ht": "Haitian Creole", "hu": "Hungarian", "hy": "Armenian", "id": "Indonesian",
+	"ig": "Igbo", "is": "Icelandic", "it": "Italian", "ja": "Japanese", "jv": "Javanese",
+	"ka": "Georgian", "kk": "Kazakh", "km": "Khmer", "kn": "Kannada", "ko": "Korean",
+	"ku": "Kurdish", "ky": "Kyrgyz", "lb": "Luxembourgish", "lg": "Ganda", "li": "Limburgish",
+	"lij": "Ligurian", "lmo": "Lombard", "ln": "Lingala", "lo": "Lao", "lt": "Lithuanian",
+	"lv": "Latvian", "mg": "Malagasy", "mi": "Maori", "mk": "Macedonian", "ml": "Malayalam",
+	"mn": "Mongolian", "mr": "Marathi", "ms": "Malay", "mt": "Maltese", "my": "Burmese",
+	"nb": "Norwegian Bokmål", "ne": "Nepali", "nl": "Dutch", "nn": "Norwegian Nynorsk", "ny": "Chichewa",
+	"oc": "Occitan", "pa": "Punjabi", "pl": "Polish", "ps": "Pashto", "pt": "Portuguese",
+	"ro": "Romanian", "ru": "Russian", "rw": "Kinyarwanda", "sa": "Sanskrit", "sc": "Sardinian",
+	"scn": "Sicilian", "sd": "Sindhi", "si": "Sinhala", "sk": "Slovak", "sl": "Slovenian",
+	"sm": "Samoan", "sn": "Shona", "so": "Somali", "sq": "Albanian", "sr": "Serbian",
+	"su": "Sundanese", "sv": "Swedish", "sw": "Swahili", "ta": "Tamil", "te": "Telugu",
+	"tg": "Tajik", "th": "Thai", "ti": "Tigrinya", "tk": "Turkmen", "tl": "Tagalog",
+	"tn": "Tswana", "tr": "Turkish", "ts": "Tsonga", "tt": "Tatar", "uk": "Ukrainian",
+	"ur": "Urdu", "uz": "Uzbek", "vi": "Vietnamese", "wo": "Wolof", "xh": "Xhosa",
+	"yi": "Yiddish", "yo": "Yoruba", "zh": "Chinese", "zu": "Zulu",
+}
+
+var scriptNames = map[string]string{
+	"Arab": "Arabic", "Cyrl": "Cyrillic", "Hans": "Simplified", "Hant": "Traditional", "Latn": "Latin",
+}
+
+var regionNames = map[string]string{
+	"419": "Latin America", "AR": "Argentina", "AT": "Austria", "AU": "Australia", "BE": "Belgium",
+	"BR": "Brazil", "CA": "Canada", "CH": "Switzerland", "CN": "China", "DE": "Germany",
+	"ES": "Spain", "FR": "France", "GB": "United Kingdom", "HK": "Hong Kong", "IE": "Ireland",
+	"IN": "India", "IT": "Italy", "MX": "Mexico", "NZ": "New Zealand", "PT": "Portugal",
+	"SG": "Singapore", "TW": "Taiwan", "US": "United States",
+}
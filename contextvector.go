@@ -0,0 +1,227 @@
+package modernmt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrMissingContextVector = errors.New("no context vector for target")
+
+type ContextVectorEntry struct {
+	Memory int64
+	Score  float32
+}
+
+type ContextVector []ContextVectorEntry
+
+func ParseContextVector(s string) (ContextVector, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ContextVector{}, nil
+	}
+
+	var vector ContextVector
+	for _, el := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(el), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid context vector entry: %q", el)
+		}
+
+		memory, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context vector memory id: %q", parts[0])
+		}
+
+		score, err := strconv.ParseFloat(parts[1], 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context vector score: %q", parts[1])
+		}
+
+		vector = append(vector, ContextVectorEntry{
+			Memory: memory,
+			Score:  float32(score),
+		})
+	}
+
+	return vector.sorted(), nil
+}
+
+func makeContextVectors(data map[string]interface{}) (map[string]ContextVector, error) {
+	vectors := map[string]ContextVector{}
+
+	raw, ok := data["vectors"].(map[string]interface{})
+	if !ok {
+		return vectors, nil
+	}
+
+	for target, el := range raw {
+		s, ok := el.(string)
+		if !ok {
+			continue
+		}
+
+		vector, err := ParseContextVector(s)
+		if err != nil {
+			return nil, err
+		}
+		vectors[target] = vector
+	}
+
+	return vectors, nil
+}
+
+func contextVectorOf(vectors map[string]ContextVector, target string) (ContextVector, error) {
+	vector, ok := vectors[target]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingContextVector, target)
+	}
+	return vector, nil
+}
+
+// the API answers with normalized tags: the vectors are returned under the tags given by the caller
+func rekeyContextVectors(vectors map[string]ContextVector, targets []string,
+	normalized []string) map[string]ContextVector {
+
+	res := make(map[string]ContextVector, len(targets))
+	for i, target := range targets {
+		if vector, ok := vectors[normalized[i]]; ok {
+			res[target] = vector
+		} else if vector, ok := vectors[target]; ok {
+			res[target] = vector
+		}
+	}
+
+	return res
+}
+
+// merges the vectors averaging their scores, a memory missing from a vector counts as zero
+func MergeContextVectors(vectors ...ContextVector) ContextVector {
+	return MergeWeightedContextVectors(vectors, nil)
+}
+
+func MergeWeightedContextVectors(vectors []ContextVector, weights []float32) ContextVector {
+	scores := map[int64]float32{}
+	var total float32
+
+	for i, vector := range vectors {
+		weight := float32(1)
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		total += weight
+
+		for _, entry := range vector {
+			scores[entry.Memory] += entry.Score * weight
+		}
+	}
+
+	merged := ContextVector{}
+	if total == 0 {
+		return merged
+	}
+
+	for memory, score := range scores {
+		merged = append(merged, ContextVectorEntry{
+			Memory: memory,
+			Score:  score / total,
+		})
+	}
+
+	return merged.sorted()
+}
+
+func (re ContextVector) Score(memory int64) float32 {
+	for _, entry := range re {
+		if entry.Memory == memory {
+			return entry.Score
+		}
+	}
+	return 0
+}
+
+func (re ContextVector) Memories() []int64 {
+	memories := make([]int64, len(re))
+	for i, entry := range re {
+		memories[i] = entry.Memory
+	}
+	return memories
+}
+
+// multiplies the score of the given memory by factor; a missing memory has score 0 and is left out
+func (re ContextVector) Boost(memory int64, factor float32) ContextVector {
+	boosted := make(ContextVector, len(re))
+	for i, entry := range re {
+		if entry.Memory == memory {
+			entry.Score *= factor
+		}
+		boosted[i] = entry
+	}
+
+	return boosted.sorted()
+}
+
+// sets the score of the given memory, adding it if missing
+func (re ContextVector) Set(memory int64, score float32) ContextVector {
+	res := make(ContextVector, 0, len(re)+1)
+	for _, entry := range re {
+		if entry.Memory != memory {
+			res = append(res, entry)
+		}
+	}
+	res = append(res, ContextVectorEntry{Memory: memory, Score: score})
+
+	return res.sorted()
+}
+
+func (re ContextVector) Exclude(memories ...int64) ContextVector {
+	excluded := map[int64]bool{}
+	for _, memory := range memories {
+		excluded[memory] = true
+	}
+
+	res := ContextVector{}
+	for _, entry := range re {
+		if !excluded[entry.Memory] {
+			res = append(res, entry)
+		}
+	}
+
+	return res
+}
+
+func (re ContextVector) Top(n int) ContextVector {
+	if n <= 0 {
+		return ContextVector{}
+	}
+
+	sorted := re.sorted()
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func (re ContextVector) String() string {
+	parts := make([]string, len(re))
+	for i, entry := range re {
+		parts[i] = strconv.FormatInt(entry.Memory, 10) + ":" + strconv.FormatFloat(float64(entry.Score), 'f', -1, 32)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (re ContextVector) sorted() ContextVector {
+	sorted := make(ContextVector, len(re))
+	copy(sorted, re)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].Memory < sorted[j].Memory
+	})
+
+	return sorted
+}
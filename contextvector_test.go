@@ -0,0 +1,110 @@
+package modernmt
+
+import "testing"
+
+func TestParseContextVector(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", ""},
+		{"1:0.5", "1:0.5"},
+		{"1:0.2,2:0.7", "2:0.7,1:0.2"},
+		{" 3:0.1 , 2:0.1 ", "2:0.1,3:0.1"},
+	}
+
+	for _, test := range tests {
+		vector, err := ParseContextVector(test.s)
+		if err != nil {
+			t.Errorf("ParseContextVector(%q): unexpected error %v", test.s, err)
+			continue
+		}
+		if got := vector.String(); got != test.want {
+			t.Errorf("ParseContextVector(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+
+	for _, s := range []string{"1", "a:0.5", "1:x", "1:0.5:2", "1:0.5,"} {
+		if _, err := ParseContextVector(s); err == nil {
+			t.Errorf("ParseContextVector(%q): want an error", s)
+		}
+	}
+}
+
+func TestContextVectorTop(t *testing.T) {
+	vector, _ := ParseContextVector("1:0.1,2:0.9,3:0.5")
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{-1, ""},
+		{0, ""},
+		{1, "2:0.9"},
+		{2, "2:0.9,3:0.5"},
+		{5, "2:0.9,3:0.5,1:0.1"},
+	}
+
+	for _, test := range tests {
+		if got := vector.Top(test.n).String(); got != test.want {
+			t.Errorf("Top(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
+
+func TestContextVectorBoostAndSet(t *testing.T) {
+	vector, _ := ParseContextVector("1:0.2,2:0.4")
+
+	if got := vector.Boost(1, 3).String(); got != "1:0.6,2:0.4" {
+		t.Errorf("Boost(1, 3) = %q", got)
+	}
+	if got := vector.Boost(9, 3).String(); got != "2:0.4,1:0.2" {
+		t.Errorf("Boost of a missing memory = %q, want the vector unchanged", got)
+	}
+	if got := vector.Set(9, 0.3).String(); got != "2:0.4,9:0.3,1:0.2" {
+		t.Errorf("Set(9, 0.3) = %q", got)
+	}
+	if got := vector.Exclude(2).String(); got != "1:0.2" {
+		t.Errorf("Exclude(2) = %q", got)
+	}
+}
+
+func TestMergeContextVectors(t *testing.T) {
+	a, _ := ParseContextVector("1:0.4,2:0.2")
+	b, _ := ParseContextVector("1:0.2,3:0.6")
+
+	tests := []struct {
+		name    string
+		vectors []ContextVector
+		weights []float32
+		want    map[int64]float32
+	}{
+		{"average", []ContextVector{a, b}, nil, map[int64]float32{1: 0.3, 2: 0.1, 3: 0.3}},
+		{"weighted", []ContextVector{a, b}, []float32{3, 1}, map[int64]float32{1: 0.35, 2: 0.15, 3: 0.15}},
+		{"single", []ContextVector{a}, nil, map[int64]float32{1: 0.4, 2: 0.2}},
+		{"zero weights", []ContextVector{a, b}, []float32{0, 0}, map[int64]float32{}},
+		{"none", nil, nil, map[int64]float32{}},
+	}
+
+	for _, test := range tests {
+		got := MergeWeightedContextVectors(test.vectors, test.weights)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: merged = %s, want %d memories", test.name, got, len(test.want))
+			continue
+		}
+
+		for i, entry := range got {
+			if diff := entry.Score - test.want[entry.Memory]; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("%s: score of memory %d = %v, want %v", test.name, entry.Memory, entry.Score,
+					test.want[entry.Memory])
+			}
+			if i > 0 && got[i-1].Score < entry.Score {
+				t.Errorf("%s: merged vector %s is not sorted by score", test.name, got)
+			}
+		}
+	}
+
+	if got := MergeContextVectors(a, b).String(); got != MergeWeightedContextVectors([]ContextVector{a, b}, nil).String() {
+		t.Errorf("MergeContextVectors = %q, want the unweighted merge", got)
+	}
+}
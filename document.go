@@ -0,0 +1,167 @@
+package modernmt
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	maxSegmentsPerRequest   = 128
+	maxCharactersPerRequest = 10240
+	maxConcurrentTargets    = 8
+)
+
+type TargetTranslations struct {
+	Translations []Translation
+	Err          error
+
+	// set when the document context vector could not be computed, the segments are translated without it
+	ContextVectorErr error
+}
+
+func (re *ModernMT) TranslateDocumentSegments(source string, targets []string, segments []string, hints []int64,
+	limit int, options *TranslateOptions) (map[string][]Translation, error) {
+	_hints := toSliceOfString(hints)
+	return re.TranslateDocumentSegmentsWithKeys(source, targets, segments, _hints, limit, options)
+}
+
+func (re *ModernMT) TranslateDocumentSegmentsWithKeys(source string, targets []string, segments []string,
+	hints []string, limit int, options *TranslateOptions) (map[string][]Translation, error) {
+
+	result := map[string][]Translation{}
+	if len(segments) == 0 {
+		return result, nil
+	}
+
+	vectors, err := re.GetContextVectorsByKeys(source, targets, strings.Join(segments, "\n"), hints, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for target, res := range re.translateTargets(source, targets, segments, hints, vectors, options) {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		result[target] = res.Translations
+	}
+
+	return result, nil
+}
+
+func (re *ModernMT) TranslateMulti(source string, targets []string, segments []string,
+	options *TranslateOptions) (map[string]TargetTranslations, error) {
+	return re.TranslateMultiAdaptiveWithKeys(source, targets, segments, nil, 0, options)
+}
+
+func (re *ModernMT) TranslateMultiAdaptive(source string, targets []string, segments []string, hints []int64,
+	limit int, options *TranslateOptions) (map[string]TargetTranslations, error) {
+	_hints := toSliceOfString(hints)
+	return re.TranslateMultiAdaptiveWithKeys(source, targets, segments, _hints, limit, options)
+}
+
+// errors are reported per target, a failed context vector request falls back to translating without it
+func (re *ModernMT) TranslateMultiAdaptiveWithKeys(source string, targets []string, segments []string,
+	hints []string, limit int, options *TranslateOptions) (map[string]TargetTranslations, error) {
+
+	if len(segments) == 0 {
+		result := map[string]TargetTranslations{}
+		for _, target := range targets {
+			result[target] = TargetTranslations{Translations: []Translation{}}
+		}
+		return result, nil
+	}
+
+	vectors, vectorErr := re.GetContextVectorsByKeys(source, targets, strings.Join(segments, "\n"), hints, limit)
+
+	result := re.translateTargets(source, targets, segments, hints, vectors, options)
+	if vectorErr != nil {
+		for target, res := range result {
+			res.ContextVectorErr = vectorErr
+			result[target] = res
+		}
+	}
+
+	return result, nil
+}
+
+func (re *ModernMT) translateTargets(source string, targets []string, segments []string, hints []string,
+	vectors map[string]ContextVector, options *TranslateOptions) map[string]TargetTranslations {
+
+	result := map[string]TargetTranslations{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrentTargets)
+
+	for _, target := range targets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(target string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			// a missing vector only means that no memory matches the document
+			translations, err := re.translateChunked(TranslateRequest{
+				Source:        Language(source),
+				Target:        Language(target),
+				Segments:      segments,
+				HintKeys:      hints,
+				ContextVector: vectors[target].String(),
+				Options:       options,
+			})
+
+			mutex.Lock()
+			result[target] = TargetTranslations{Translations: translations, Err: err}
+			mutex.Unlock()
+		}(target)
+	}
+
+	wg.Wait()
+	return result
+}
+
+func (re *ModernMT) translateChunked(request TranslateRequest) ([]Translation, error) {
+	translations := make([]Translation, 0, len(request.Segments))
+
+	for _, chunk := range chunkSegments(request.Segments) {
+		_request := request
+		_request.Segments = chunk
+
+		res, err := re.TranslateWithRequest(_request)
+		if err != nil {
+			return nil, err
+		}
+
+		translations = append(translations, res...)
+	}
+
+	return translations, nil
+}
+
+func chunkSegments(segments []string) [][]string {
+	var chunks [][]string
+	var chunk []string
+	chars := 0
+
+	for _, segment := range segments {
+		length := utf8.RuneCountInString(segment)
+		if len(chunk) > 0 && (len(chunk) >= maxSegmentsPerRequest || chars+length > maxCharactersPerRequest) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chars = 0
+		}
+
+		chunk = append(chunk, segment)
+		chars += length
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
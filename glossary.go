@@ -0,0 +1,268 @@
+package modernmt
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrInvalidGlossary = errors.New("invalid glossary")
+
+func (re GlossaryType) validate() error {
+	switch re {
+	case GlossaryUnidirectional, GlossaryEquivalent:
+		return nil
+	default:
+		return fmt.Errorf("invalid glossary type: %s", re)
+	}
+}
+
+// a glossary entry holds at most one term per language: a unidirectional entry maps its first (source) term
+// to the second (target) one, while the terms of an equivalent entry are interchangeable
+type GlossaryEntry struct {
+	Type  GlossaryType
+	Terms []GlossaryTerm
+}
+
+func Unidirectional(source GlossaryTerm, target GlossaryTerm) GlossaryEntry {
+	return GlossaryEntry{
+		Type:  GlossaryUnidirectional,
+		Terms: []GlossaryTerm{source, target},
+	}
+}
+
+func Equivalent(terms ...GlossaryTerm) GlossaryEntry {
+	return GlossaryEntry{
+		Type:  GlossaryEquivalent,
+		Terms: terms,
+	}
+}
+
+func (re GlossaryEntry) Validate() error {
+	_, err := re.normalize()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGlossary, err)
+	}
+	return nil
+}
+
+// returns the terms with normalized language tags
+func (re GlossaryEntry) normalize() ([]GlossaryTerm, error) {
+	if err := re.Type.validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case re.Type == GlossaryUnidirectional && len(re.Terms) != 2:
+		return nil, fmt.Errorf("unidirectional entries need exactly 2 terms, got %d", len(re.Terms))
+	case len(re.Terms) < 2:
+		return nil, fmt.Errorf("equivalent entries need at least 2 terms, got %d", len(re.Terms))
+	}
+
+	terms := make([]GlossaryTerm, len(re.Terms))
+	languages := map[Language]bool{}
+	for i, term := range re.Terms {
+		language, err := ParseLanguage(term.Language)
+		if err != nil {
+			return nil, err
+		}
+		if languages[language] {
+			return nil, fmt.Errorf("more than one term for language %s", language)
+		}
+		if strings.TrimSpace(term.Term) == "" {
+			return nil, fmt.Errorf("empty term for language %s", language)
+		}
+
+		languages[language] = true
+		terms[i] = GlossaryTerm{Term: term.Term, Language: language.String()}
+	}
+
+	return terms, nil
+}
+
+// builds the CSV expected by ImportGlossary: a header with one column per language, an optional tuid column,
+// and one entry per row
+type GlossaryBuilder struct {
+	_type     GlossaryType
+	languages []string
+	columns   map[string]int
+	entries   []glossaryEntryRow
+	tuids     map[string]bool
+	terms     map[string]int
+	err       error
+}
+
+type glossaryEntryRow struct {
+	tuid  string
+	terms []string
+}
+
+func NewGlossaryBuilder(_type GlossaryType, languages ...string) *GlossaryBuilder {
+	builder := &GlossaryBuilder{
+		_type:   _type,
+		columns: map[string]int{},
+		tuids:   map[string]bool{},
+		terms:   map[string]int{},
+	}
+
+	if err := _type.validate(); err != nil {
+		return builder.fail(fmt.Errorf("%w: %v", ErrInvalidGlossary, err))
+	}
+
+	switch {
+	case _type == GlossaryUnidirectional && len(languages) != 2:
+		return builder.fail(fmt.Errorf("%w: unidirectional glossaries need exactly 2 languages, got %d",
+			ErrInvalidGlossary, len(languages)))
+	case len(languages) < 2:
+		return builder.fail(fmt.Errorf("%w: equivalent glossaries need at least 2 languages, got %d",
+			ErrInvalidGlossary, len(languages)))
+	}
+
+	for _, tag := range languages {
+		language, err := ParseLanguage(tag)
+		if err != nil {
+			return builder.fail(fmt.Errorf("%w: %v", ErrInvalidGlossary, err))
+		}
+		if _, ok := builder.columns[language.String()]; ok {
+			return builder.fail(fmt.Errorf("%w: duplicate language column: %s", ErrInvalidGlossary, language))
+		}
+
+		builder.columns[language.String()] = len(builder.languages)
+		builder.languages = append(builder.languages, language.String())
+	}
+
+	return builder
+}
+
+func (re *GlossaryBuilder) fail(err error) *GlossaryBuilder {
+	if re.err == nil {
+		re.err = err
+	}
+	return re
+}
+
+func (re *GlossaryBuilder) Add(terms []GlossaryTerm) *GlossaryBuilder {
+	return re.AddWithTuid("", terms)
+}
+
+func (re *GlossaryBuilder) AddEntry(entry GlossaryEntry) *GlossaryBuilder {
+	return re.AddEntryWithTuid("", entry)
+}
+
+func (re *GlossaryBuilder) AddEntryWithTuid(tuid string, entry GlossaryEntry) *GlossaryBuilder {
+	if re.err == nil && entry.Type != re._type {
+		return re.fail(fmt.Errorf("%w: entry %d: %s entry in a %s glossary",
+			ErrInvalidGlossary, len(re.entries), entry.Type, re._type))
+	}
+
+	return re.AddWithTuid(tuid, entry.Terms)
+}
+
+func (re *GlossaryBuilder) AddWithTuid(tuid string, terms []GlossaryTerm) *GlossaryBuilder {
+	if re.err != nil {
+		return re
+	}
+
+	index := len(re.entries)
+	invalid := func(format string, args ...interface{}) *GlossaryBuilder {
+		return re.fail(fmt.Errorf("%w: entry %d: %s", ErrInvalidGlossary, index, fmt.Sprintf(format, args...)))
+	}
+
+	if tuid != "" && re.tuids[tuid] {
+		return invalid("duplicate tuid: %s", tuid)
+	}
+
+	terms, err := GlossaryEntry{Type: re._type, Terms: terms}.normalize()
+	if err != nil {
+		return invalid("%v", err)
+	}
+
+	row := glossaryEntryRow{tuid: tuid, terms: make([]string, len(re.languages))}
+	for _, term := range terms {
+		column, ok := re.columns[term.Language]
+		if !ok {
+			return invalid("language %s is not a column of the glossary", term.Language)
+		}
+
+		row.terms[column] = term.Term
+	}
+
+	// source terms of unidirectional glossaries, and every term of equivalent ones, must be unique
+	keys := make([]string, 0, len(terms))
+	for column, term := range row.terms {
+		if term == "" || (re._type == GlossaryUnidirectional && column > 0) {
+			continue
+		}
+
+		key := re.languages[column] + "\x00" + term
+		if previous, ok := re.terms[key]; ok {
+			return invalid("duplicate %s term %q, already in entry %d", re.languages[column], term, previous)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		re.terms[key] = index
+	}
+	if tuid != "" {
+		re.tuids[tuid] = true
+	}
+	re.entries = append(re.entries, row)
+
+	return re
+}
+
+func (re *GlossaryBuilder) Type() GlossaryType {
+	return re._type
+}
+
+func (re *GlossaryBuilder) Len() int {
+	return len(re.entries)
+}
+
+// returns the first error found while building the glossary
+func (re *GlossaryBuilder) Validate() error {
+	if re.err != nil {
+		return re.err
+	}
+	if len(re.entries) == 0 {
+		return fmt.Errorf("%w: no entries", ErrInvalidGlossary)
+	}
+	return nil
+}
+
+func (re *GlossaryBuilder) WriteCsv(w io.Writer) error {
+	if err := re.Validate(); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+
+	withTuids := len(re.tuids) > 0
+	header := re.languages
+	if withTuids {
+		header = append([]string{"tuid"}, re.languages...)
+	}
+
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range re.entries {
+		record := entry.terms
+		if withTuids {
+			record = append([]string{entry.tuid}, entry.terms...)
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,123 @@
+package modernmt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGlossaryBuilderCsv(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *GlossaryBuilder
+		want    string
+	}{
+		{
+			name: "unidirectional",
+			builder: NewGlossaryBuilder(GlossaryUnidirectional, "en", "it").
+				Add([]GlossaryTerm{{"car", "en"}, {"auto", "it"}}).
+				Add([]GlossaryTerm{{"bus", "EN"}, {"auto", "it"}}),
+			want: "en,it\ncar,auto\nbus,auto\n",
+		},
+		{
+			name: "equivalent with tuids",
+			builder: NewGlossaryBuilder(GlossaryEquivalent, "en", "pt_br", "it").
+				AddWithTuid("t1", []GlossaryTerm{{"bus", "pt-BR"}, {"bus", "en"}}).
+				Add([]GlossaryTerm{{"car", "en"}, {"auto, vettura", "it"}}),
+			want: "tuid,en,pt-BR,it\nt1,bus,bus,\n,car,,\"auto, vettura\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.builder.WriteCsv(&buf); err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s: csv =\n%s\nwant\n%s", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestGlossaryBuilderErrors(t *testing.T) {
+	car := []GlossaryTerm{{"car", "en"}, {"auto", "it"}}
+
+	tests := []struct {
+		name    string
+		builder *GlossaryBuilder
+	}{
+		{"invalid type", NewGlossaryBuilder("bidirectional", "en", "it")},
+		{"too many languages", NewGlossaryBuilder(GlossaryUnidirectional, "en", "it", "de")},
+		{"too few languages", NewGlossaryBuilder(GlossaryEquivalent, "en")},
+		{"duplicate column", NewGlossaryBuilder(GlossaryEquivalent, "en", "EN")},
+		{"no entries", NewGlossaryBuilder(GlossaryUnidirectional, "en", "it")},
+		{"unknown language", NewGlossaryBuilder(GlossaryUnidirectional, "en", "de").Add(car)},
+		{"duplicate source term", NewGlossaryBuilder(GlossaryUnidirectional, "en", "it").Add(car).Add(car)},
+		{"duplicate tuid", NewGlossaryBuilder(GlossaryUnidirectional, "en", "it").
+			AddWithTuid("1", car).
+			AddWithTuid("1", []GlossaryTerm{{"bus", "en"}, {"bus", "it"}})},
+		{"empty term", NewGlossaryBuilder(GlossaryEquivalent, "en", "it").
+			Add([]GlossaryTerm{{"car", "en"}, {" ", "it"}})},
+		{"same language twice", NewGlossaryBuilder(GlossaryEquivalent, "en", "it").
+			Add([]GlossaryTerm{{"car", "en"}, {"auto", "en"}})},
+		{"single term", NewGlossaryBuilder(GlossaryEquivalent, "en", "it").
+			Add([]GlossaryTerm{{"car", "en"}})},
+	}
+
+	for _, test := range tests {
+		err := test.builder.Validate()
+		if !errors.Is(err, ErrInvalidGlossary) {
+			t.Errorf("%s: got %v, want ErrInvalidGlossary", test.name, err)
+		}
+		if err = test.builder.WriteCsv(&bytes.Buffer{}); !errors.Is(err, ErrInvalidGlossary) {
+			t.Errorf("%s: WriteCsv returned %v, want ErrInvalidGlossary", test.name, err)
+		}
+	}
+}
+
+func TestGlossaryEntryValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry GlossaryEntry
+		valid bool
+	}{
+		{"unidirectional", Unidirectional(GlossaryTerm{"car", "en"}, GlossaryTerm{"auto", "it"}), true},
+		{"equivalent", Equivalent(GlossaryTerm{"car", "en"}, GlossaryTerm{"auto", "it"}, GlossaryTerm{"Auto", "de"}), true},
+		{"invalid type", GlossaryEntry{Type: "bidirectional", Terms: []GlossaryTerm{{"car", "en"}, {"auto", "it"}}}, false},
+		{"unidirectional with 3 terms", GlossaryEntry{Type: GlossaryUnidirectional,
+			Terms: []GlossaryTerm{{"car", "en"}, {"auto", "it"}, {"Auto", "de"}}}, false},
+		{"single term", Equivalent(GlossaryTerm{"car", "en"}), false},
+		{"invalid language", Equivalent(GlossaryTerm{"car", "english1"}, GlossaryTerm{"auto", "it"}), false},
+		{"same language twice", Equivalent(GlossaryTerm{"car", "en"}, GlossaryTerm{"auto", "EN"}), false},
+		{"empty term", Unidirectional(GlossaryTerm{"car", "en"}, GlossaryTerm{"", "it"}), false},
+	}
+
+	for _, test := range tests {
+		err := test.entry.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidGlossary) {
+			t.Errorf("%s: got %v, want ErrInvalidGlossary", test.name, err)
+		}
+	}
+}
+
+func TestGlossaryBuilderEntries(t *testing.T) {
+	car := Unidirectional(GlossaryTerm{"car", "en"}, GlossaryTerm{"auto", "it"})
+
+	builder := NewGlossaryBuilder(GlossaryUnidirectional, "en", "it").AddEntryWithTuid("1", car)
+	var buf bytes.Buffer
+	if err := builder.WriteCsv(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "tuid,en,it\n1,car,auto\n"; buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	builder = NewGlossaryBuilder(GlossaryEquivalent, "en", "it").AddEntry(car)
+	if err := builder.Validate(); !errors.Is(err, ErrInvalidGlossary) {
+		t.Errorf("unidirectional entry in an equivalent glossary: got %v, want ErrInvalidGlossary", err)
+	}
+}
@@ -2,16 +2,91 @@ package modernmt
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
+const idempotencyKeyHeader = "x-idempotency-key"
+
+type IdempotencyKeyGenerator func(method string, path string, data map[string]interface{}) string
+
+// opt-in generator deriving the key from the request content: separate calls with the same content share a key,
+// so the server applies only the first of them
+func ContentHashIdempotencyKey(method string, path string, data map[string]interface{}) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+
+	// map keys are sorted by the encoder, so the same content always produces the same key
+	jsonBytes, err := json.Marshal(data)
+	if err == nil {
+		hash.Write(jsonBytes)
+	} else {
+		hash.Write([]byte(fmt.Sprint(data)))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// default generator: a new key for every call, reused by its retries
+func RandomIdempotencyKey(string, string, map[string]interface{}) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key, val := range headers {
+		if strings.EqualFold(key, name) && val != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (re *WriteOptions) headers() map[string]string {
+	if re == nil || re.IdempotencyKey == "" {
+		return nil
+	}
+	return map[string]string{idempotencyKeyHeader: re.IdempotencyKey}
+}
+
+func isRetryable(err error) bool {
+	if apiError, ok := err.(APIError); ok {
+		return apiError.Status == 429 || apiError.Status >= 500
+	}
+
+	_, ok := err.(net.Error)
+	return ok
+}
+
+type uploadFile interface {
+	io.Reader
+	Name() string
+}
+
+type namedReader struct {
+	io.Reader
+	name string
+}
+
+func (re namedReader) Name() string {
+	return re.name
+}
+
 func createHttpClient(baseUrl string, headers map[string]string) *httpClient {
 	return &httpClient{
 		baseUrl: baseUrl,
@@ -20,26 +95,46 @@ func createHttpClient(baseUrl string, headers map[string]string) *httpClient {
 	}
 }
 
+// the body is written while it is sent, so that uploaded files are never held in memory
 func (re *httpClient) _createMultipartRequest(path string, data map[string]interface{},
-	files map[string]*os.File) (*http.Request, error) {
+	files map[string]uploadFile) (*http.Request, error) {
 
-	var body bytes.Buffer
-	w := multipart.NewWriter(&body)
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+
+	req, err := http.NewRequest("POST", re.baseUrl+path, pr)
+	if err != nil {
+		_ = pr.Close()
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	go func() {
+		_ = pw.CloseWithError(writeMultipart(w, data, files))
+	}()
+
+	return req, nil
+}
+
+func writeMultipart(w *multipart.Writer, data map[string]interface{}, files map[string]uploadFile) error {
+	defer func() {
+		for _, file := range files {
+			if closer, ok := file.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}()
 
 	for param, file := range files {
 		fw, err := w.CreateFormFile(param, file.Name())
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		_, err = io.Copy(fw, file)
 		if err != nil {
-			return nil, err
-		}
-
-		err = file.Close()
-		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
@@ -58,23 +153,11 @@ func (re *httpClient) _createMultipartRequest(path string, data map[string]inter
 
 		err := w.WriteField(key, s)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	err := w.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", re.baseUrl+path, &body)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", w.FormDataContentType())
-
-	return req, nil
+	return w.Close()
 }
 
 func (re *httpClient) _createJsonRequest(path string, data map[string]interface{}) (*http.Request, error) {
@@ -100,7 +183,7 @@ func (re *httpClient) _createJsonRequest(path string, data map[string]interface{
 }
 
 func (re *httpClient) _createRequest(path string, data map[string]interface{},
-	files map[string]*os.File) (*http.Request, error) {
+	files map[string]uploadFile) (*http.Request, error) {
 
 	if files != nil {
 		return re._createMultipartRequest(path, data, files)
@@ -109,7 +192,40 @@ func (re *httpClient) _createRequest(path string, data map[string]interface{},
 	return re._createJsonRequest(path, data)
 }
 
-func (re *httpClient) send(method string, path string, data map[string]interface{}, files map[string]*os.File, headers map[string]string) (interface{}, error) {
+func (re *httpClient) send(method string, path string, data map[string]interface{}, files map[string]uploadFile, headers map[string]string) (interface{}, error) {
+	// uploaded files are consumed by the first attempt, so they are never retried
+	if files != nil || re.retries == 0 {
+		return re._send(method, path, data, files, headers)
+	}
+
+	if (method == "POST" || method == "PUT") && !hasHeader(headers, idempotencyKeyHeader) {
+		generator := re.idempotencyKey
+		if generator == nil {
+			generator = RandomIdempotencyKey
+		}
+
+		_headers := map[string]string{
+			idempotencyKeyHeader: generator(method, path, data),
+		}
+		for key, val := range headers {
+			_headers[key] = val
+		}
+		headers = _headers
+	}
+
+	delay := re.retryDelay
+	for attempt := 0; ; attempt++ {
+		res, err := re._send(method, path, data, nil, headers)
+		if err == nil || attempt >= re.retries || !isRetryable(err) {
+			return res, err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (re *httpClient) _send(method string, path string, data map[string]interface{}, files map[string]uploadFile, headers map[string]string) (interface{}, error) {
 
 	req, err := re._createRequest(path, data, files)
 	if err != nil {
@@ -147,6 +263,14 @@ func (re *httpClient) send(method string, path string, data map[string]interface
 	var result map[string]interface{}
 	err = json.Unmarshal(body, &result)
 	if err != nil {
+		// e.g. gateway errors returned by a proxy
+		if res.StatusCode >= 500 {
+			return nil, APIError{
+				Status:  res.StatusCode,
+				Type:    "HttpError",
+				Message: res.Status,
+			}
+		}
 		return nil, err
 	}
 
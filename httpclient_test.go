@@ -0,0 +1,152 @@
+package modernmt
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedRequest struct {
+	path string
+	keys []string
+}
+
+// serves the given statuses in order, then 200, and records the idempotency keys of every request
+func newRetryServer(t *testing.T, statuses ...int) (*httpClient, func() []recordedRequest) {
+	var mutex sync.Mutex
+	var requests []recordedRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, recordedRequest{path: r.URL.Path, keys: r.Header.Values(idempotencyKeyHeader)})
+		status := 200
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		mutex.Unlock()
+
+		response := map[string]interface{}{"status": status, "data": "ok"}
+		if status != 200 {
+			response["error"] = map[string]interface{}{"type": "Error", "message": http.StatusText(status)}
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	client := createHttpClient(server.URL, nil)
+	client.retries = 3
+	client.retryDelay = time.Millisecond
+
+	return client, func() []recordedRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return requests
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		fails    bool
+	}{
+		{"success", nil, 1, false},
+		{"server errors", []int{503, 500}, 3, false},
+		{"rate limited", []int{429}, 2, false},
+		{"client error", []int{400}, 1, true},
+		{"retries exhausted", []int{503, 503, 503, 503, 503}, 4, true},
+	}
+
+	for _, test := range tests {
+		client, requests := newRetryServer(t, test.statuses...)
+
+		_, err := client.send("POST", "/memories", map[string]interface{}{"name": "a"}, nil, nil)
+		if test.fails != (err != nil) {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+
+		received := requests()
+		if len(received) != test.attempts {
+			t.Errorf("%s: got %d attempts, want %d", test.name, len(received), test.attempts)
+			continue
+		}
+		for _, request := range received {
+			if len(request.keys) != 1 || request.keys[0] != received[0].keys[0] {
+				t.Errorf("%s: attempts sent keys %v, want the same key", test.name, request.keys)
+			}
+		}
+	}
+}
+
+func TestSendIdempotencyKeys(t *testing.T) {
+	client, requests := newRetryServer(t)
+	data := map[string]interface{}{"name": "a"}
+
+	// separate calls with the same content are separate writes
+	_, _ = client.send("POST", "/memories", data, nil, nil)
+	_, _ = client.send("POST", "/memories", data, nil, nil)
+	// keys given by the caller win over generated ones
+	_, _ = client.send("POST", "/memories", data, nil, map[string]string{"X-Idempotency-Key": "mine"})
+	// reads have no key
+	_, _ = client.send("GET", "/memories", nil, nil, nil)
+
+	received := requests()
+	if len(received[0].keys) != 1 || len(received[1].keys) != 1 || received[0].keys[0] == received[1].keys[0] {
+		t.Errorf("calls with the same content sent keys %v and %v, want different keys",
+			received[0].keys, received[1].keys)
+	}
+	if len(received[2].keys) != 1 || received[2].keys[0] != "mine" {
+		t.Errorf("caller key: got %v, want [mine]", received[2].keys)
+	}
+	if len(received[3].keys) != 0 {
+		t.Errorf("read sent keys %v", received[3].keys)
+	}
+
+	client.idempotencyKey = ContentHashIdempotencyKey
+	_, _ = client.send("POST", "/memories", data, nil, nil)
+	_, _ = client.send("POST", "/memories", data, nil, nil)
+
+	received = requests()
+	if received[4].keys[0] != received[5].keys[0] {
+		t.Errorf("content hash keys %v and %v differ", received[4].keys, received[5].keys)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{APIError{Status: 429}, true},
+		{APIError{Status: 500}, true},
+		{APIError{Status: 503}, true},
+		{APIError{Status: 400}, false},
+		{APIError{Status: 404}, false},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{errors.New("invalid character"), false},
+	}
+
+	for _, test := range tests {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestHasHeader(t *testing.T) {
+	headers := map[string]string{"X-Idempotency-Key": "a", "x-empty": ""}
+
+	if !hasHeader(headers, idempotencyKeyHeader) {
+		t.Error("header names are not matched ignoring case")
+	}
+	if hasHeader(headers, "x-empty") || hasHeader(nil, idempotencyKeyHeader) {
+		t.Error("empty or missing headers are reported")
+	}
+}
@@ -0,0 +1,231 @@
+package modernmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Language string
+
+type UnsupportedLanguageError struct {
+	Language    string
+	Suggestions []string
+}
+
+func (re UnsupportedLanguageError) Error() string {
+	if len(re.Suggestions) == 0 {
+		return fmt.Sprintf("unsupported language: %s", re.Language)
+	}
+	return fmt.Sprintf("unsupported language: %s (did you mean %s?)", re.Language, strings.Join(re.Suggestions, ", "))
+}
+
+// parses a BCP-47 tag, accepting "_" as separator, and normalizes the case of its subtags
+func ParseLanguage(tag string) (Language, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("invalid language tag: %q", tag)
+	}
+
+	subtags := strings.Split(strings.Replace(tag, "_", "-", -1), "-")
+
+	language := strings.ToLower(subtags[0])
+	if !isAlpha(language) || len(language) < 2 || len(language) > 8 || len(language) == 4 {
+		return "", fmt.Errorf("invalid language tag: %q", tag)
+	}
+
+	normalized := []string{language}
+	seenScript, seenRegion := false, false
+
+	for i, subtag := range subtags[1:] {
+		switch {
+		case subtag == "":
+			return "", fmt.Errorf("invalid language tag: %q", tag)
+		case i == 0 && len(subtag) == 3 && isAlpha(subtag) && !seenScript && !seenRegion:
+			// extended language subtag
+			normalized = append(normalized, strings.ToLower(subtag))
+		case len(subtag) == 4 && isAlpha(subtag) && !seenScript && !seenRegion:
+			seenScript = true
+			normalized = append(normalized, strings.ToUpper(subtag[:1])+strings.ToLower(subtag[1:]))
+		case (len(subtag) == 2 && isAlpha(subtag) || len(subtag) == 3 && isDigit(subtag)) && !seenRegion:
+			seenRegion = true
+			normalized = append(normalized, strings.ToUpper(subtag))
+		case (len(subtag) >= 5 && len(subtag) <= 8 || len(subtag) == 4 && isDigit(subtag[:1])) && isAlphaNum(subtag):
+			// variants of 4 characters start with a digit
+			normalized = append(normalized, strings.ToLower(subtag))
+		default:
+			return "", fmt.Errorf("invalid language tag: %q", tag)
+		}
+	}
+
+	return Language(strings.Join(normalized, "-")), nil
+}
+
+func (re Language) String() string {
+	return string(re)
+}
+
+func (re Language) Base() string {
+	return languageBase(string(re))
+}
+
+func (re Language) Script() string {
+	for _, subtag := range strings.Split(string(re), "-")[1:] {
+		if len(subtag) == 4 && isAlpha(subtag) {
+			return subtag
+		}
+	}
+	return ""
+}
+
+func (re Language) Region() string {
+	for _, subtag := range strings.Split(string(re), "-")[1:] {
+		if len(subtag) == 2 && isAlpha(subtag) || len(subtag) == 3 && isDigit(subtag) {
+			return subtag
+		}
+	}
+	return ""
+}
+
+// validates language tags against the supported languages, refreshed every refresh interval
+// (one hour if not positive)
+func (re *ModernMT) EnableLanguageValidation(refresh time.Duration) {
+	re.Languages.SetTTL(refresh)
+	re.validateLanguages = true
+}
+
+func (re *ModernMT) ValidateLanguage(tag string) (Language, error) {
+	language, err := ParseLanguage(tag)
+	if err != nil {
+		return "", err
+	}
+
+	supported, err := re.Languages.supported()
+	if err != nil {
+		return "", err
+	}
+
+	if !supported[language] {
+		return "", UnsupportedLanguageError{
+			Language:    string(language),
+			Suggestions: suggestLanguages(language, supported),
+		}
+	}
+
+	return language, nil
+}
+
+// normalizes a language tag, validating it against the supported languages if enabled; empty tags are left to the API
+func (re *ModernMT) normalizeLanguage(tag string) (string, error) {
+	if tag == "" {
+		return "", nil
+	}
+
+	var language Language
+	var err error
+	if re.validateLanguages {
+		language, err = re.ValidateLanguage(tag)
+	} else {
+		language, err = ParseLanguage(tag)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(language), nil
+}
+
+func (re *ModernMT) normalizeLanguages(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	res := make([]string, len(tags))
+	for i, tag := range tags {
+		language, err := re.normalizeLanguage(tag)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = language
+	}
+
+	return res, nil
+}
+
+// suggests the supported variants of the same base language or, failing that, the closest tags by spelling
+func suggestLanguages(language Language, supported map[Language]bool) []string {
+	var suggestions []string
+	for candidate := range supported {
+		if candidate.Base() == language.Base() {
+			suggestions = append(suggestions, string(candidate))
+		}
+	}
+
+	if len(suggestions) == 0 {
+		for candidate := range supported {
+			if editDistance(strings.ToLower(string(candidate)), strings.ToLower(string(language))) <= 2 {
+				suggestions = append(suggestions, string(candidate))
+			}
+		}
+	}
+
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDigit(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isAlphaNum(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
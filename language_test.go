@@ -0,0 +1,65 @@
+package modernmt
+
+import "testing"
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Language
+	}{
+		{"en", "en"},
+		{"EN", "en"},
+		{" it ", "it"},
+		{"pt_BR", "pt-BR"},
+		{"pt-br", "pt-BR"},
+		{"zh-hans", "zh-Hans"},
+		{"zh_hant_tw", "zh-Hant-TW"},
+		{"sr-latn", "sr-Latn"},
+		{"es-419", "es-419"},
+		{"zh-yue", "zh-yue"},
+		{"de-CH-1901", "de-CH-1901"},
+	}
+
+	for _, test := range tests {
+		got, err := ParseLanguage(test.tag)
+		if err != nil {
+			t.Errorf("ParseLanguage(%q): unexpected error %v", test.tag, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseLanguage(%q) = %q, want %q", test.tag, got, test.want)
+		}
+	}
+}
+
+func TestParseLanguageInvalid(t *testing.T) {
+	for _, tag := range []string{"", "e", "english1", "en-", "en--US", "abcd", "en-US-GB", "en-Latn-Cyrl", "en-!"} {
+		if got, err := ParseLanguage(tag); err == nil {
+			t.Errorf("ParseLanguage(%q) = %q, want an error", tag, got)
+		}
+	}
+}
+
+func TestLanguageSubtags(t *testing.T) {
+	tests := []struct {
+		tag                  Language
+		base, script, region string
+	}{
+		{"en", "en", "", ""},
+		{"pt-BR", "pt", "", "BR"},
+		{"zh-Hant-TW", "zh", "Hant", "TW"},
+		{"es-419", "es", "", "419"},
+	}
+
+	for _, test := range tests {
+		if got := test.tag.Base(); got != test.base {
+			t.Errorf("%s.Base() = %q, want %q", test.tag, got, test.base)
+		}
+		if got := test.tag.Script(); got != test.script {
+			t.Errorf("%s.Script() = %q, want %q", test.tag, got, test.script)
+		}
+		if got := test.tag.Region(); got != test.region {
+			t.Errorf("%s.Region() = %q, want %q", test.tag, got, test.region)
+		}
+	}
+}
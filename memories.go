@@ -1,10 +1,77 @@
 package modernmt
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
+var ErrMemoryNotFound = errors.New("memory not found")
+
+type MemorySortField int
+
+const (
+	SortById MemorySortField = iota
+	SortByName
+	SortByCreationDate
+)
+
+type MemoryListOptions struct {
+	NamePrefix   string
+	NameContains string
+	CreatedAfter time.Time
+	SortBy       MemorySortField
+	Descending   bool
+}
+
+func (re *MemoryListOptions) matches(memory Memory) bool {
+	name := strings.ToLower(memory.Name)
+
+	if re.NamePrefix != "" && !strings.HasPrefix(name, strings.ToLower(re.NamePrefix)) {
+		return false
+	}
+	if re.NameContains != "" && !strings.Contains(name, strings.ToLower(re.NameContains)) {
+		return false
+	}
+	if !re.CreatedAfter.IsZero() && !memory.CreationTime.After(re.CreatedAfter) {
+		return false
+	}
+
+	return true
+}
+
+// iterates over memories matching a filter. The API has no pagination: the whole list is downloaded by
+// Iterate and held until the iterator is discarded, only the decoding and filtering are done while iterating
+type MemoryIterator struct {
+	data    []interface{}
+	options MemoryListOptions
+	index   int
+	current Memory
+}
+
+func (re *MemoryIterator) Next() bool {
+	for re.index < len(re.data) {
+		memory := makeMemory(re.data[re.index].(map[string]interface{}))
+		re.index++
+
+		if re.options.matches(memory) {
+			re.current = memory
+			return true
+		}
+	}
+
+	return false
+}
+
+func (re *MemoryIterator) Memory() Memory {
+	return re.current
+}
+
 func (re *memoryServices) List() ([]Memory, error) {
 	res, err := re.client.send("GET", "/memories", nil, nil, nil)
 	if err != nil {
@@ -19,6 +86,79 @@ func (re *memoryServices) List() ([]Memory, error) {
 	return memories, nil
 }
 
+func (re *memoryServices) ListWithOptions(options MemoryListOptions) ([]Memory, error) {
+	it, err := re.Iterate(MemoryListOptions{
+		NamePrefix:   options.NamePrefix,
+		NameContains: options.NameContains,
+		CreatedAfter: options.CreatedAfter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memories := []Memory{}
+	for it.Next() {
+		memories = append(memories, it.Memory())
+	}
+
+	sort.SliceStable(memories, func(i, j int) bool {
+		a, b := memories[i], memories[j]
+		if options.Descending {
+			a, b = b, a
+		}
+
+		switch options.SortBy {
+		case SortByName:
+			return a.Name < b.Name
+		case SortByCreationDate:
+			return a.CreationTime.Before(b.CreationTime)
+		default:
+			return a.Id < b.Id
+		}
+	})
+
+	return memories, nil
+}
+
+// lists the memories with a single request, since the API does not support pagination, and returns an
+// iterator that decodes and filters them in the order returned by the API
+func (re *memoryServices) Iterate(options MemoryListOptions) (*MemoryIterator, error) {
+	res, err := re.client.send("GET", "/memories", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := res.([]interface{})
+
+	return &MemoryIterator{
+		data:    data,
+		options: options,
+	}, nil
+}
+
+func (re *memoryServices) FindByName(name string) (Memory, error) {
+	it, err := re.Iterate(MemoryListOptions{})
+	if err != nil {
+		return Memory{}, err
+	}
+
+	var matches []Memory
+	for it.Next() {
+		if it.Memory().Name == name {
+			matches = append(matches, it.Memory())
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Memory{}, fmt.Errorf("%w: %s", ErrMemoryNotFound, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return Memory{}, fmt.Errorf("ambiguous memory name %q: %d memories found", name, len(matches))
+	}
+}
+
 func (re *memoryServices) Get(id int64) (Memory, error) {
 	_id := strconv.FormatInt(id, 10)
 	return re.GetByKey(_id)
@@ -109,7 +249,10 @@ func (re *memoryServices) DeleteByKey(id string) (Memory, error) {
 		return Memory{}, err
 	}
 
-	return makeMemory(res.(map[string]interface{})), nil
+	memory := makeMemory(res.(map[string]interface{}))
+	re.contextVectors.invalidate(id, memory.Id)
+
+	return memory, nil
 }
 
 func (re *memoryServices) Add(id int64, source string, target string, sentence string, translation string,
@@ -131,6 +274,17 @@ func (re *memoryServices) AddWithSession(id int64, source string, target string,
 
 func (re *memoryServices) AddWithSessionByKey(id string, source string, target string,
 	sentence string, translation string, tuid string, session string) (ImportJob, error) {
+	return re.AddWithOptionsByKey(id, source, target, sentence, translation, tuid, &WriteOptions{Session: session})
+}
+
+func (re *memoryServices) AddWithOptions(id int64, source string, target string, sentence string, translation string,
+	tuid string, options *WriteOptions) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.AddWithOptionsByKey(_id, source, target, sentence, translation, tuid, options)
+}
+
+func (re *memoryServices) AddWithOptionsByKey(id string, source string, target string,
+	sentence string, translation string, tuid string, options *WriteOptions) (ImportJob, error) {
 
 	data := map[string]interface{}{
 		"source":      source,
@@ -143,17 +297,20 @@ func (re *memoryServices) AddWithSessionByKey(id string, source string, target s
 		data["tuid"] = tuid
 	}
 
-	if session != "" {
-		data["session"] = session
+	if options != nil && options.Session != "" {
+		data["session"] = options.Session
 	}
 
 	path := "/memories/" + id + "/content"
-	res, err := re.client.send("POST", path, data, nil, nil)
+	res, err := re.client.send("POST", path, data, nil, options.headers())
 	if err != nil {
 		return ImportJob{}, err
 	}
 
-	return makeImportJob(res.(map[string]interface{})), nil
+	job := makeImportJob(res.(map[string]interface{}))
+	re.contextVectors.invalidate(id, job.Memory)
+
+	return job, nil
 }
 
 func (re *memoryServices) Replace(id int64, tuid string, source string, target string, sentence string,
@@ -175,6 +332,17 @@ func (re *memoryServices) ReplaceWithSession(id int64, tuid string, source strin
 
 func (re *memoryServices) ReplaceWithSessionByKey(id string, tuid string, source string, target string, sentence string,
 	translation string, session string) (ImportJob, error) {
+	return re.ReplaceWithOptionsByKey(id, tuid, source, target, sentence, translation, &WriteOptions{Session: session})
+}
+
+func (re *memoryServices) ReplaceWithOptions(id int64, tuid string, source string, target string, sentence string,
+	translation string, options *WriteOptions) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ReplaceWithOptionsByKey(_id, tuid, source, target, sentence, translation, options)
+}
+
+func (re *memoryServices) ReplaceWithOptionsByKey(id string, tuid string, source string, target string,
+	sentence string, translation string, options *WriteOptions) (ImportJob, error) {
 
 	data := map[string]interface{}{
 		"tuid":        tuid,
@@ -184,17 +352,20 @@ func (re *memoryServices) ReplaceWithSessionByKey(id string, tuid string, source
 		"translation": translation,
 	}
 
-	if session != "" {
-		data["session"] = session
+	if options != nil && options.Session != "" {
+		data["session"] = options.Session
 	}
 
 	path := "/memories/" + id + "/content"
-	res, err := re.client.send("PUT", path, data, nil, nil)
+	res, err := re.client.send("PUT", path, data, nil, options.headers())
 	if err != nil {
 		return ImportJob{}, err
 	}
 
-	return makeImportJob(res.(map[string]interface{})), nil
+	job := makeImportJob(res.(map[string]interface{}))
+	re.contextVectors.invalidate(id, job.Memory)
+
+	return job, nil
 }
 
 func (re *memoryServices) ImportTmxPath(id int64, path string, compression string) (ImportJob, error) {
@@ -217,13 +388,63 @@ func (re *memoryServices) ImportTmx(id int64, tmx *os.File, compression string)
 }
 
 func (re *memoryServices) ImportTmxByKey(id string, tmx *os.File, compression string) (ImportJob, error) {
+	return re.importTmx(id, tmx, compression)
+}
+
+func (re *memoryServices) ImportTmxReader(id int64, tmx io.Reader, compression string) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ImportTmxReaderByKey(_id, tmx, compression)
+}
+
+func (re *memoryServices) ImportTmxReaderByKey(id string, tmx io.Reader, compression string) (ImportJob, error) {
+	name := "memory.tmx"
+	if compression == "gzip" {
+		name += ".gz"
+	}
+
+	return re.importTmx(id, namedReader{Reader: tmx, name: name}, compression)
+}
+
+func (re *memoryServices) ImportTranslationUnits(id int64, units []TranslationUnit) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ImportTranslationUnitsByKey(_id, units)
+}
+
+// the TMX is generated while it is uploaded, without a temporary file
+func (re *memoryServices) ImportTranslationUnitsByKey(id string, units []TranslationUnit) (ImportJob, error) {
+	if len(units) == 0 {
+		return ImportJob{}, errors.New("no translation units to import")
+	}
+
+	return uploadGenerated(func(w io.Writer) error {
+		return WriteTmx(w, TmxHeader{SourceLanguage: units[0].Source}, units)
+	}, func(r io.Reader) (ImportJob, error) {
+		return re.ImportTmxReaderByKey(id, r, "")
+	})
+}
+
+// uploads the output of write while it is generated, through a pipe
+func uploadGenerated(write func(w io.Writer) error, upload func(r io.Reader) (ImportJob, error)) (ImportJob, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(write(pw))
+	}()
+
+	job, err := upload(pr)
+	// unblocks the writer if the upload stopped early
+	_ = pr.Close()
+
+	return job, err
+}
+
+func (re *memoryServices) importTmx(id string, tmx uploadFile, compression string) (ImportJob, error) {
 	data := map[string]interface{}{}
 
 	if compression != "" {
 		data["compression"] = compression
 	}
 
-	files := map[string]*os.File{
+	files := map[string]uploadFile{
 		"tmx": tmx,
 	}
 
@@ -233,7 +454,10 @@ func (re *memoryServices) ImportTmxByKey(id string, tmx *os.File, compression st
 		return ImportJob{}, err
 	}
 
-	return makeImportJob(res.(map[string]interface{})), nil
+	job := makeImportJob(res.(map[string]interface{}))
+	re.contextVectors.invalidate(id, job.Memory)
+
+	return job, nil
 }
 
 func (re *memoryServices) AddToGlossary(id int64, terms []GlossaryTerm, _type string, tuid string) (ImportJob, error) {
@@ -243,6 +467,17 @@ func (re *memoryServices) AddToGlossary(id int64, terms []GlossaryTerm, _type st
 
 func (re *memoryServices) AddToGlossaryByKey(id string, terms []GlossaryTerm, _type string,
 	tuid string) (ImportJob, error) {
+	return re.AddToGlossaryWithOptionsByKey(id, terms, _type, tuid, nil)
+}
+
+func (re *memoryServices) AddToGlossaryWithOptions(id int64, terms []GlossaryTerm, _type string, tuid string,
+	options *WriteOptions) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.AddToGlossaryWithOptionsByKey(_id, terms, _type, tuid, options)
+}
+
+func (re *memoryServices) AddToGlossaryWithOptionsByKey(id string, terms []GlossaryTerm, _type string,
+	tuid string, options *WriteOptions) (ImportJob, error) {
 
 	data := map[string]interface{}{
 		"terms": terms,
@@ -254,7 +489,7 @@ func (re *memoryServices) AddToGlossaryByKey(id string, terms []GlossaryTerm, _t
 	}
 
 	path := "/memories/" + id + "/glossary"
-	res, err := re.client.send("POST", path, data, nil, nil)
+	res, err := re.client.send("POST", path, data, nil, options.headers())
 	if err != nil {
 		return ImportJob{}, err
 	}
@@ -271,6 +506,17 @@ func (re *memoryServices) ReplaceInGlossary(id int64, terms []GlossaryTerm, _typ
 
 func (re *memoryServices) ReplaceInGlossaryByKey(id string, terms []GlossaryTerm, _type string,
 	tuid string) (ImportJob, error) {
+	return re.ReplaceInGlossaryWithOptionsByKey(id, terms, _type, tuid, nil)
+}
+
+func (re *memoryServices) ReplaceInGlossaryWithOptions(id int64, terms []GlossaryTerm, _type string, tuid string,
+	options *WriteOptions) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ReplaceInGlossaryWithOptionsByKey(_id, terms, _type, tuid, options)
+}
+
+func (re *memoryServices) ReplaceInGlossaryWithOptionsByKey(id string, terms []GlossaryTerm, _type string,
+	tuid string, options *WriteOptions) (ImportJob, error) {
 
 	data := map[string]interface{}{
 		"terms": terms,
@@ -282,7 +528,7 @@ func (re *memoryServices) ReplaceInGlossaryByKey(id string, terms []GlossaryTerm
 	}
 
 	path := "/memories/" + id + "/glossary"
-	res, err := re.client.send("PUT", path, data, nil, nil)
+	res, err := re.client.send("PUT", path, data, nil, options.headers())
 	if err != nil {
 		return ImportJob{}, err
 	}
@@ -290,6 +536,34 @@ func (re *memoryServices) ReplaceInGlossaryByKey(id string, terms []GlossaryTerm
 	return makeImportJob(res.(map[string]interface{})), nil
 }
 
+func (re *memoryServices) AddGlossaryEntry(id int64, entry GlossaryEntry, tuid string) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.AddGlossaryEntryByKey(_id, entry, tuid)
+}
+
+func (re *memoryServices) AddGlossaryEntryByKey(id string, entry GlossaryEntry, tuid string) (ImportJob, error) {
+	terms, err := entry.normalize()
+	if err != nil {
+		return ImportJob{}, fmt.Errorf("%w: %v", ErrInvalidGlossary, err)
+	}
+
+	return re.AddToGlossaryByKey(id, terms, string(entry.Type), tuid)
+}
+
+func (re *memoryServices) ReplaceGlossaryEntry(id int64, entry GlossaryEntry, tuid string) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ReplaceGlossaryEntryByKey(_id, entry, tuid)
+}
+
+func (re *memoryServices) ReplaceGlossaryEntryByKey(id string, entry GlossaryEntry, tuid string) (ImportJob, error) {
+	terms, err := entry.normalize()
+	if err != nil {
+		return ImportJob{}, fmt.Errorf("%w: %v", ErrInvalidGlossary, err)
+	}
+
+	return re.ReplaceInGlossaryByKey(id, terms, string(entry.Type), tuid)
+}
+
 func (re *memoryServices) ImportGlossaryPath(id int64, path string, _type string,
 	compression string) (ImportJob, error) {
 
@@ -315,6 +589,45 @@ func (re *memoryServices) ImportGlossary(id int64, csv *os.File, _type string, c
 
 func (re *memoryServices) ImportGlossaryByKey(id string, csv *os.File, _type string,
 	compression string) (ImportJob, error) {
+	return re.importGlossary(id, csv, _type, compression)
+}
+
+func (re *memoryServices) ImportGlossaryReader(id int64, csv io.Reader, _type string,
+	compression string) (ImportJob, error) {
+
+	_id := strconv.FormatInt(id, 10)
+	return re.ImportGlossaryReaderByKey(_id, csv, _type, compression)
+}
+
+func (re *memoryServices) ImportGlossaryReaderByKey(id string, csv io.Reader, _type string,
+	compression string) (ImportJob, error) {
+
+	name := "glossary.csv"
+	if compression == "gzip" {
+		name += ".gz"
+	}
+
+	return re.importGlossary(id, namedReader{Reader: csv, name: name}, _type, compression)
+}
+
+func (re *memoryServices) ImportGlossaryEntries(id int64, glossary *GlossaryBuilder) (ImportJob, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.ImportGlossaryEntriesByKey(_id, glossary)
+}
+
+// the glossary is validated before the upload, and its CSV is generated while it is uploaded
+func (re *memoryServices) ImportGlossaryEntriesByKey(id string, glossary *GlossaryBuilder) (ImportJob, error) {
+	if err := glossary.Validate(); err != nil {
+		return ImportJob{}, err
+	}
+
+	return uploadGenerated(glossary.WriteCsv, func(r io.Reader) (ImportJob, error) {
+		return re.ImportGlossaryReaderByKey(id, r, string(glossary.Type()), "")
+	})
+}
+
+func (re *memoryServices) importGlossary(id string, csv uploadFile, _type string,
+	compression string) (ImportJob, error) {
 
 	data := map[string]interface{}{
 		"type": _type,
@@ -324,7 +637,7 @@ func (re *memoryServices) ImportGlossaryByKey(id string, csv *os.File, _type str
 		data["compression"] = compression
 	}
 
-	files := map[string]*os.File{
+	files := map[string]uploadFile{
 		"csv": csv,
 	}
 
@@ -343,5 +656,12 @@ func (re *memoryServices) GetImportStatus(uuid string) (ImportJob, error) {
 		return ImportJob{}, err
 	}
 
-	return makeImportJob(res.(map[string]interface{})), nil
+	job := makeImportJob(res.(map[string]interface{}))
+
+	// vectors requested while the job was running may not reflect its content yet
+	if job.Progress >= 1 && job.Memory != 0 {
+		re.contextVectors.invalidate("", job.Memory)
+	}
+
+	return job, nil
 }
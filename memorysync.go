@@ -0,0 +1,401 @@
+package modernmt
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSyncStateFile    = ".modernmt-sync.json"
+	defaultSyncPollInterval = 5 * time.Second
+	defaultSyncTimeout      = 30 * time.Minute
+)
+
+type SyncOptions struct {
+	// defaults to .modernmt-sync.json in the synchronized directory
+	StateFile string
+
+	// files, by path relative to the directory, connected to memories by external id instead of by name
+	ExternalIds map[string]string
+
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+type SyncStatus string
+
+const (
+	SyncImported  SyncStatus = "imported"
+	SyncUnchanged SyncStatus = "unchanged"
+	SyncFailed    SyncStatus = "failed"
+	SyncRemoved   SyncStatus = "removed"
+)
+
+type SyncFileResult struct {
+	File   string
+	Memory Memory
+	Job    ImportJob
+	Status SyncStatus
+	Err    error
+}
+
+type SyncReport struct {
+	Imported  []SyncFileResult
+	Unchanged []SyncFileResult
+	Failed    []SyncFileResult
+
+	// files synchronized before and no longer in the directory, whose units are still in their memories
+	Removed []SyncFileResult
+}
+
+type syncState struct {
+	Files map[string]syncFileState `json:"files"`
+}
+
+type syncFileState struct {
+	Hash   string `json:"hash"`
+	Memory int64  `json:"memory"`
+}
+
+func (re *memoryServices) WaitForImport(job ImportJob, pollInterval time.Duration,
+	timeout time.Duration) (ImportJob, error) {
+
+	deadline := time.Now().Add(timeout)
+	for job.Progress < 1 {
+		if timeout > 0 && time.Now().After(deadline) {
+			return job, fmt.Errorf("import job %s not completed after %v", job.Id, timeout)
+		}
+
+		time.Sleep(pollInterval)
+
+		var err error
+		job, err = re.GetImportStatus(job.Id)
+		if err != nil {
+			return job, err
+		}
+	}
+
+	return job, nil
+}
+
+// imports the TMX files of a directory that are new or changed since the last synchronization. A changed
+// file is imported again in full, so its units must carry tuids: the API then replaces them instead of adding
+// duplicates, and changed files with units missing a tuid are reported as failed. The state is saved after
+// every import, so that an interrupted synchronization does not import completed files again.
+//
+// Synchronization only adds and replaces units: units removed from a changed file stay in its memory, and
+// files removed from the directory are reported as Removed while their units stay in the memory. Files are
+// connected to the memory named after them, so new files with the same name in different folders fail
+// unless they are connected by ExternalIds
+func (re *memoryServices) Sync(dir string, options *SyncOptions) (SyncReport, error) {
+	_options := SyncOptions{}
+	if options != nil {
+		_options = *options
+	}
+	if _options.StateFile == "" {
+		_options.StateFile = filepath.Join(dir, defaultSyncStateFile)
+	}
+	if _options.PollInterval == 0 {
+		_options.PollInterval = defaultSyncPollInterval
+	}
+	if _options.Timeout == 0 {
+		_options.Timeout = defaultSyncTimeout
+	}
+
+	state, err := loadSyncState(_options.StateFile)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	files, err := listTmxFiles(dir)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	collisions := syncNameCollisions(files, &_options)
+
+	var report SyncReport
+	for _, file := range files {
+		result := re.syncFile(dir, file, state, &_options, collisions[file])
+
+		switch result.Status {
+		case SyncImported:
+			report.Imported = append(report.Imported, result)
+
+			err = saveSyncState(_options.StateFile, state)
+			if err != nil {
+				return report, err
+			}
+		case SyncUnchanged:
+			report.Unchanged = append(report.Unchanged, result)
+		default:
+			report.Failed = append(report.Failed, result)
+		}
+	}
+
+	listed := make(map[string]bool, len(files))
+	for _, file := range files {
+		listed[file] = true
+	}
+	for file, fileState := range state.Files {
+		if !listed[file] {
+			report.Removed = append(report.Removed, SyncFileResult{
+				File:   file,
+				Memory: Memory{Id: fileState.Memory},
+				Status: SyncRemoved,
+			})
+		}
+	}
+	sort.Slice(report.Removed, func(i, j int) bool {
+		return report.Removed[i].File < report.Removed[j].File
+	})
+
+	return report, nil
+}
+
+// returns, for each file connected by name, the other files with the same memory name
+func syncNameCollisions(files []string, options *SyncOptions) map[string][]string {
+	names := map[string][]string{}
+	for _, file := range files {
+		if _, ok := options.ExternalIds[file]; !ok {
+			name := syncMemoryName(file)
+			names[name] = append(names[name], file)
+		}
+	}
+
+	collisions := map[string][]string{}
+	for _, group := range names {
+		if len(group) < 2 {
+			continue
+		}
+		for _, file := range group {
+			for _, other := range group {
+				if other != file {
+					collisions[file] = append(collisions[file], other)
+				}
+			}
+		}
+	}
+
+	return collisions
+}
+
+func (re *memoryServices) syncFile(dir string, file string, state *syncState, options *SyncOptions,
+	collisions []string) SyncFileResult {
+
+	result := SyncFileResult{File: file}
+	fail := func(err error) SyncFileResult {
+		result.Status = SyncFailed
+		result.Err = err
+		return result
+	}
+
+	path := filepath.Join(dir, file)
+	hash, err := fileHash(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	previous, known := state.Files[file]
+	if known && previous.Hash == hash {
+		result.Status = SyncUnchanged
+		result.Memory = Memory{Id: previous.Memory}
+		return result
+	}
+
+	compression := ""
+	if strings.HasSuffix(file, ".gz") {
+		compression = "gzip"
+	}
+
+	var memory Memory
+	if known {
+		err = checkTmxTuids(path, compression)
+		if err == nil {
+			memory, err = re.Get(previous.Memory)
+		}
+	} else if len(collisions) > 0 {
+		err = fmt.Errorf("memory %s would also be synchronized from %s, connect the files by external id",
+			syncMemoryName(file), strings.Join(collisions, ", "))
+	} else {
+		memory, err = re.resolveSyncMemory(file, options)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result.Memory = memory
+
+	job, err := re.ImportTmxPath(memory.Id, path, compression)
+	if err != nil {
+		return fail(err)
+	}
+
+	job, err = re.WaitForImport(job, options.PollInterval, options.Timeout)
+	result.Job = job
+	if err != nil {
+		return fail(err)
+	}
+
+	state.Files[file] = syncFileState{Hash: hash, Memory: memory.Id}
+	result.Status = SyncImported
+	return result
+}
+
+func syncMemoryName(file string) string {
+	name := filepath.Base(file)
+	name = strings.TrimSuffix(name, ".gz")
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func (re *memoryServices) resolveSyncMemory(file string, options *SyncOptions) (Memory, error) {
+	name := syncMemoryName(file)
+
+	if externalId, ok := options.ExternalIds[file]; ok {
+		return re.Connect(name, "", externalId)
+	}
+
+	memory, err := re.FindByName(name)
+	if errors.Is(err, ErrMemoryNotFound) {
+		return re.Create(name, "")
+	}
+
+	return memory, err
+}
+
+// re-importing units without a tuid would add them again instead of replacing them
+func checkTmxTuids(path string, compression string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var r io.Reader = file
+	if compression == "gzip" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		r = gz
+	}
+
+	reader, err := NewTmxReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		unit, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if unit.Tuid == "" {
+			return fmt.Errorf("changed file has a unit without tuid at line %d, importing it again would "+
+				"duplicate its units", unit.Line)
+		}
+	}
+}
+
+func listTmxFiles(dir string) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		lower := strings.ToLower(path)
+		if info.IsDir() || !(strings.HasSuffix(lower, ".tmx") || strings.HasSuffix(lower, ".tmx.gz")) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func loadSyncState(path string) (*syncState, error) {
+	state := &syncState{Files: map[string]syncFileState{}}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync state file %s: %v", path, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]syncFileState{}
+	}
+
+	return state, nil
+}
+
+func saveSyncState(path string, state *syncState) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, content)
+}
+
+// the content is written to a temporary file first, so that an interrupted write never corrupts it
+func writeFileAtomic(path string, content []byte) error {
+	tmp := path + ".tmp" + strconv.Itoa(os.Getpid())
+	err := os.WriteFile(tmp, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,70 @@
+package modernmt
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const syncTmx = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+<header srclang="en" datatype="plaintext"/>
+<body>
+<tu tuid="1"><tuv xml:lang="en"><seg>a</seg></tuv><tuv xml:lang="it"><seg>b</seg></tuv></tu>
+</body>
+</tmx>
+`
+
+func TestSyncReportsCollisionsAndRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a/foo.tmx", "b/foo.tmx"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(syncTmx), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state, _ := json.Marshal(syncState{Files: map[string]syncFileState{"old/bar.tmx": {Hash: "x", Memory: 3}}})
+	stateFile := filepath.Join(dir, defaultSyncStateFile)
+	if err := os.WriteFile(stateFile, state, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is sent to the API: both files fail before resolving their memory
+	mmt := Create("api-key")
+	mmt.SetBaseUrl("http://127.0.0.1:0")
+
+	report, err := mmt.Memories.Sync(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Failed) != 2 || len(report.Imported) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, result := range report.Failed {
+		if result.Err == nil || !strings.Contains(result.Err.Error(), "memory foo would also be synchronized") {
+			t.Errorf("%s: got %v, want a collision error", result.File, result.Err)
+		}
+	}
+
+	if len(report.Removed) != 1 || report.Removed[0].File != "old/bar.tmx" || report.Removed[0].Memory.Id != 3 ||
+		report.Removed[0].Status != SyncRemoved {
+		t.Errorf("removed = %+v", report.Removed)
+	}
+}
+
+func TestSyncNameCollisions(t *testing.T) {
+	files := []string{"a/foo.tmx", "b/foo.tmx.gz", "c/foo.tmx", "bar.tmx"}
+	options := &SyncOptions{ExternalIds: map[string]string{"c/foo.tmx": "ext"}}
+
+	collisions := syncNameCollisions(files, options)
+	if len(collisions) != 2 || collisions["a/foo.tmx"][0] != "b/foo.tmx.gz" || collisions["b/foo.tmx.gz"][0] != "a/foo.tmx" {
+		t.Errorf("collisions = %v", collisions)
+	}
+}
@@ -2,25 +2,36 @@ package modernmt
 
 import (
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type ModernMT struct {
-	client   *httpClient
-	pk       *rsa.PublicKey
-	pkTime   int64
-	Memories memoryServices
+	client            *httpClient
+	pk                *rsa.PublicKey
+	pkTime            int64
+	callbackOptions   *CallbackOptions
+	seenCallbacks     *replayCache
+	contextVectors    *contextVectorCache
+	validateLanguages bool
+	Languages         *LanguageCatalog
+	Memories          memoryServices
 }
 
 type memoryServices struct {
-	client *httpClient
+	client         *httpClient
+	contextVectors *contextVectorCache
 }
 
 type httpClient struct {
-	baseUrl string
-	headers map[string]string
-	client  *http.Client
+	baseUrl        string
+	headers        map[string]string
+	client         *http.Client
+	retries        int
+	retryDelay     time.Duration
+	idempotencyKey IdempotencyKeyGenerator
 }
 
 type APIError struct {
@@ -33,6 +44,42 @@ func (re APIError) Error() string {
 	return fmt.Sprintf("%s: %s", re.Type, re.Message)
 }
 
+type CallbackOptions struct {
+	Issuer            string
+	Leeway            time.Duration
+	MaxAge            time.Duration
+	RequireExpiration bool
+
+	// name of the claim holding the SHA-256 digest of the callback body, if any
+	BodyHashClaim string
+
+	// how long seen token ids are remembered, defaults to 24 hours
+	ReplayWindow time.Duration
+}
+
+type Priority string
+
+const (
+	PriorityNormal     Priority = "normal"
+	PriorityBackground Priority = "background"
+)
+
+type Format string
+
+const (
+	FormatPlainText Format = "text/plain"
+	FormatXml       Format = "text/xml"
+	FormatHtml      Format = "text/html"
+	FormatXliff     Format = "application/xliff+xml"
+)
+
+type GlossaryType string
+
+const (
+	GlossaryUnidirectional GlossaryType = "unidirectional"
+	GlossaryEquivalent     GlossaryType = "equivalent"
+)
+
 type TranslateOptions struct {
 	Priority           string
 	ProjectId          string
@@ -50,6 +97,40 @@ type TranslateOptions struct {
 	IdempotencyKey string
 }
 
+// per-call options of memory and glossary writes
+type WriteOptions struct {
+	// sent by Add and Replace only
+	Session string
+
+	// overrides the key of the client generator, so that retries of the same write are applied once
+	IdempotencyKey string
+}
+
+type TranslateRequest struct {
+	Source   Language
+	Target   Language
+	Segments []string
+
+	// memory hints, either as ids or as keys
+	Hints    []int64
+	HintKeys []string
+
+	ContextVector string
+	Options       *TranslateOptions
+}
+
+func (re *TranslateRequest) hints() ([]string, error) {
+	if re.Hints != nil && re.HintKeys != nil {
+		return nil, errors.New("hints must be set either as ids or as keys, not both")
+	}
+
+	if re.Hints != nil {
+		return toSliceOfString(re.Hints), nil
+	}
+
+	return re.HintKeys, nil
+}
+
 type Translation struct {
 	Translation         string
 	ContextVector       string
@@ -99,6 +180,7 @@ type Memory struct {
 	Name         string
 	Description  string
 	CreationDate string
+	CreationTime time.Time
 }
 
 func makeMemory(data map[string]interface{}) Memory {
@@ -107,6 +189,7 @@ func makeMemory(data map[string]interface{}) Memory {
 		Name:         data["name"].(string),
 		CreationDate: data["creationDate"].(string),
 	}
+	memory.CreationTime, _ = parseTimestamp(memory.CreationDate)
 
 	description, ok := data["description"].(string)
 	if ok {
@@ -116,6 +199,26 @@ func makeMemory(data map[string]interface{}) Memory {
 	return memory
 }
 
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// timestamps without a time zone are assumed to be UTC
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp: %q", s)
+}
+
 type ImportJob struct {
 	Id       string
 	Memory   int64
@@ -153,6 +256,8 @@ func makeDetectedLanguage(data map[string]interface{}) DetectedLanguage {
 type billingPeriod struct {
 	Begin           string
 	End             string
+	BeginTime       time.Time
+	EndTime         time.Time
 	Chars           int64
 	Plan            string
 	PlanDescription string
@@ -167,6 +272,7 @@ type User struct {
 	Name             string
 	Email            string
 	RegistrationDate string
+	RegistrationTime time.Time
 	Country          string
 	IsBusiness       int8
 	Status           string
@@ -175,7 +281,7 @@ type User struct {
 
 func makeUser(data map[string]interface{}) User {
 	bp := data["billingPeriod"].(map[string]interface{})
-	return User{
+	user := User{
 		Id:               int64(data["id"].(float64)),
 		Name:             data["name"].(string),
 		Email:            data["email"].(string),
@@ -195,12 +301,24 @@ func makeUser(data map[string]interface{}) User {
 			CurrencySymbol:  bp["currencySymbol"].(string),
 		},
 	}
+
+	// unparsable timestamps are left as zero times, the raw strings are always available
+	user.RegistrationTime, _ = parseTimestamp(user.RegistrationDate)
+	user.BillingPeriod.BeginTime, _ = parseTimestamp(user.BillingPeriod.Begin)
+	user.BillingPeriod.EndTime, _ = parseTimestamp(user.BillingPeriod.End)
+
+	return user
 }
 
 type QualityEstimation struct {
 	Score float32
 }
 
+type QePair struct {
+	Sentence    string
+	Translation string
+}
+
 func makeQualityEstimation(data map[string]interface{}) QualityEstimation {
 	return QualityEstimation{
 		Score: float32(data["score"].(float64)),
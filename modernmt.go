@@ -2,6 +2,7 @@ package modernmt
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 func toSliceOfString(slice []int64) []string {
@@ -44,15 +46,50 @@ func CreateWithIdentityAndClientId(apiKey string, platform string, platformVersi
 	}
 
 	client := createHttpClient("https://api.modernmt.com", headers)
-
-	return &ModernMT{
-		client: client,
-		pk:     nil,
-		pkTime: 0,
+	contextVectors := &contextVectorCache{}
+
+	mmt := &ModernMT{
+		client:         client,
+		pk:             nil,
+		pkTime:         0,
+		seenCallbacks:  newReplayCache(),
+		contextVectors: contextVectors,
 		Memories: memoryServices{
-			client: client,
+			client:         client,
+			contextVectors: contextVectors,
 		},
 	}
+	mmt.Languages = &LanguageCatalog{
+		fetch: mmt.ListSupportedLanguages,
+		ttl:   defaultLanguageCatalogTTL,
+	}
+
+	return mmt
+}
+
+func (re *ModernMT) EnableRetries(retries int, delay time.Duration) {
+	re.client.retries = retries
+	re.client.retryDelay = delay
+}
+
+func (re *ModernMT) BaseUrl() string {
+	return re.client.baseUrl
+}
+
+// sends the requests to another endpoint, e.g. a proxy or a test server
+func (re *ModernMT) SetBaseUrl(baseUrl string) {
+	re.client.baseUrl = baseUrl
+}
+
+// trusts the given key for callback signatures until the next hourly refresh from the API
+func (re *ModernMT) SetCallbackPublicKey(pk *rsa.PublicKey) {
+	re.pk = pk
+	re.pkTime = time.Now().Unix()
+}
+
+// generates the idempotency keys of retried writes, RandomIdempotencyKey by default
+func (re *ModernMT) SetIdempotencyKeyGenerator(generator IdempotencyKeyGenerator) {
+	re.client.idempotencyKey = generator
 }
 
 func (re *ModernMT) ListSupportedLanguages() ([]string, error) {
@@ -135,20 +172,52 @@ func (re *ModernMT) TranslateListAdaptive(source string, target string, q []stri
 func (re *ModernMT) TranslateListAdaptiveWithKeys(source string, target string, q []string, hints []string,
 	contextVector string, options *TranslateOptions) ([]Translation, error) {
 
+	return re.TranslateWithRequest(TranslateRequest{
+		Source:        Language(source),
+		Target:        Language(target),
+		Segments:      q,
+		HintKeys:      hints,
+		ContextVector: contextVector,
+		Options:       options,
+	})
+}
+
+func (re *ModernMT) TranslateWithRequest(request TranslateRequest) ([]Translation, error) {
+	hints, err := request.hints()
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := re.normalizeLanguage(string(request.Source))
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := re.normalizeLanguage(string(request.Target))
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]interface{}{
 		"source": source,
 		"target": target,
-		"q":      q,
+		"q":      request.Segments,
 	}
 
-	if contextVector != "" {
-		data["context_vector"] = contextVector
+	if request.ContextVector != "" {
+		data["context_vector"] = request.ContextVector
 	}
 
 	if hints != nil {
 		data["hints"] = hints
 	}
 
+	options := request.Options
+	err = options.validateGlossaries()
+	if err != nil {
+		return nil, err
+	}
+
 	if options != nil {
 		if options.Priority != "" {
 			data["priority"] = options.Priority
@@ -178,14 +247,8 @@ func (re *ModernMT) TranslateListAdaptiveWithKeys(source string, target string,
 			data["mask_profanities"] = options.MaskProfanities
 		}
 
-		glossaries := options.Glossaries
-		if glossaries != nil {
-			switch glossaries.(type) {
-			case []int64, []string:
-				data["glossaries"] = glossaries
-			default:
-				return nil, errors.New("glossaries must be a slice of int64 or string")
-			}
+		if options.Glossaries != nil {
+			data["glossaries"] = options.Glossaries
 		}
 	}
 
@@ -232,21 +295,53 @@ func (re *ModernMT) BatchTranslateListAdaptive(webhook string, source string, ta
 func (re *ModernMT) BatchTranslateListAdaptiveWithKeys(webhook string, source string, target string, q []string, hints []string,
 	contextVector string, options *TranslateOptions) (bool, error) {
 
+	return re.BatchTranslateWithRequest(webhook, TranslateRequest{
+		Source:        Language(source),
+		Target:        Language(target),
+		Segments:      q,
+		HintKeys:      hints,
+		ContextVector: contextVector,
+		Options:       options,
+	})
+}
+
+func (re *ModernMT) BatchTranslateWithRequest(webhook string, request TranslateRequest) (bool, error) {
+	hints, err := request.hints()
+	if err != nil {
+		return false, err
+	}
+
+	source, err := re.normalizeLanguage(string(request.Source))
+	if err != nil {
+		return false, err
+	}
+
+	target, err := re.normalizeLanguage(string(request.Target))
+	if err != nil {
+		return false, err
+	}
+
 	data := map[string]interface{}{
 		"webhook": webhook,
 		"source":  source,
 		"target":  target,
-		"q":       q,
+		"q":       request.Segments,
 	}
 
-	if contextVector != "" {
-		data["context_vector"] = contextVector
+	if request.ContextVector != "" {
+		data["context_vector"] = request.ContextVector
 	}
 
 	if hints != nil {
 		data["hints"] = hints
 	}
 
+	options := request.Options
+	err = options.validateGlossaries()
+	if err != nil {
+		return false, err
+	}
+
 	headers := map[string]string{}
 
 	if options != nil {
@@ -275,17 +370,11 @@ func (re *ModernMT) BatchTranslateListAdaptiveWithKeys(webhook string, source st
 			data["metadata"] = options.Metadata
 		}
 		if options.IdempotencyKey != "" {
-			headers["x-idempotency-key"] = options.IdempotencyKey
+			headers[idempotencyKeyHeader] = options.IdempotencyKey
 		}
 
-		glossaries := options.Glossaries
-		if glossaries != nil {
-			switch glossaries.(type) {
-			case []int64, []string:
-				data["glossaries"] = glossaries
-			default:
-				return false, errors.New("glossaries must be a slice of int64 or string")
-			}
+		if options.Glossaries != nil {
+			data["glossaries"] = options.Glossaries
 		}
 	}
 
@@ -298,34 +387,44 @@ func (re *ModernMT) BatchTranslateListAdaptiveWithKeys(webhook string, source st
 }
 
 func (re *ModernMT) GetContextVector(source string, target string, text string, hints []int64,
-	limit int) (string, error) {
+	limit int) (ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorByKeys(source, target, text, _hints, limit)
 }
 
 func (re *ModernMT) GetContextVectors(source string, targets []string, text string, hints []int64,
-	limit int) (map[string]interface{}, error) {
+	limit int) (map[string]ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorsByKeys(source, targets, text, _hints, limit)
 }
 
 func (re *ModernMT) GetContextVectorByKeys(source string, target string, text string, hints []string,
-	limit int) (string, error) {
+	limit int) (ContextVector, error) {
 
 	res, err := re.GetContextVectorsByKeys(source, []string{target}, text, hints, limit)
 	if err != nil {
-		return "", nil
+		return nil, err
 	}
 
-	return res[target].(string), nil
+	return contextVectorOf(res, target)
 }
 
 func (re *ModernMT) GetContextVectorsByKeys(source string, targets []string, text string, hints []string,
-	limit int) (map[string]interface{}, error) {
+	limit int) (map[string]ContextVector, error) {
+
+	source, err := re.normalizeLanguage(source)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := re.normalizeLanguages(targets)
+	if err != nil {
+		return nil, err
+	}
 
 	data := map[string]interface{}{
 		"source":  source,
-		"targets": targets,
+		"targets": normalized,
 		"text":    text,
 	}
 
@@ -337,53 +436,69 @@ func (re *ModernMT) GetContextVectorsByKeys(source string, targets []string, tex
 		data["limit"] = limit
 	}
 
+	var cacheKey string
+	if re.contextVectors.enabled() {
+		digest := sha256.Sum256([]byte(text))
+		cacheKey = contextVectorCacheKey(digest[:], source, normalized, hints, limit, "")
+		if vectors, ok := re.contextVectors.get(cacheKey); ok {
+			return rekeyContextVectors(vectors, targets, normalized), nil
+		}
+	}
+
 	res, err := re.client.send("GET", "/context-vector", data, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	vectors := res.(map[string]interface{})["vectors"]
+	vectors, err := makeContextVectors(res.(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
 
-	return vectors.(map[string]interface{}), nil
+	if cacheKey != "" {
+		re.contextVectors.put(cacheKey, hints, vectors)
+	}
+
+	return rekeyContextVectors(vectors, targets, normalized), nil
 }
 
 func (re *ModernMT) GetContextVectorFromFile(source string, target string, file *os.File, hints []int64,
-	limit int, compression string) (string, error) {
+	limit int, compression string) (ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorFromFileByKeys(source, target, file, _hints, limit, compression)
 }
 
 func (re *ModernMT) GetContextVectorsFromFile(source string, targets []string, file *os.File, hints []int64,
-	limit int, compression string) (map[string]interface{}, error) {
+	limit int, compression string) (map[string]ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorsFromFileByKeys(source, targets, file, _hints, limit, compression)
 }
 
 func (re *ModernMT) GetContextVectorFromFilePath(source string, target string, path string, hints []int64,
-	limit int, compression string) (string, error) {
+	limit int, compression string) (ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorFromFilePathByKeys(source, target, path, _hints, limit, compression)
 }
 
 func (re *ModernMT) GetContextVectorsFromFilePath(source string, targets []string, path string, hints []int64,
-	limit int, compression string) (map[string]interface{}, error) {
+	limit int, compression string) (map[string]ContextVector, error) {
 	_hints := toSliceOfString(hints)
 	return re.GetContextVectorsFromFilePathByKeys(source, targets, path, _hints, limit, compression)
 }
 
 func (re *ModernMT) GetContextVectorFromFilePathByKeys(source string, target string, path string, hints []string,
-	limit int, compression string) (string, error) {
+	limit int, compression string) (ContextVector, error) {
 
 	res, err := re.GetContextVectorsFromFilePathByKeys(source, []string{target}, path, hints, limit, compression)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return res[target].(string), nil
+	return contextVectorOf(res, target)
 }
 
 func (re *ModernMT) GetContextVectorsFromFilePathByKeys(source string, targets []string, path string, hints []string,
-	limit int, compression string) (map[string]interface{}, error) {
+	limit int, compression string) (map[string]ContextVector, error) {
 
 	file, err := os.Open(path)
 	if err != nil {
@@ -394,26 +509,39 @@ func (re *ModernMT) GetContextVectorsFromFilePathByKeys(source string, targets [
 }
 
 func (re *ModernMT) GetContextVectorFromFileByKeys(source string, target string, file *os.File, hints []string,
-	limit int, compression string) (string, error) {
+	limit int, compression string) (ContextVector, error) {
 
 	res, err := re.GetContextVectorsFromFileByKeys(source, []string{target}, file, hints, limit, compression)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return res[target].(string), nil
+	return contextVectorOf(res, target)
 }
 
 func (re *ModernMT) GetContextVectorsFromFileByKeys(source string, targets []string, file *os.File, hints []string,
-	limit int, compression string) (map[string]interface{}, error) {
+	limit int, compression string) (map[string]ContextVector, error) {
+
+	// the file is closed once uploaded, or here when the request is not sent
+	source, err := re.normalizeLanguage(source)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	normalized, err := re.normalizeLanguages(targets)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
 
-	files := map[string]*os.File{
+	files := map[string]uploadFile{
 		"content": file,
 	}
 
 	data := map[string]interface{}{
 		"source":  source,
-		"targets": targets,
+		"targets": normalized,
 	}
 
 	if hints != nil {
@@ -428,14 +556,36 @@ func (re *ModernMT) GetContextVectorsFromFileByKeys(source string, targets []str
 		data["compression"] = compression
 	}
 
+	var cacheKey string
+	if re.contextVectors.enabled() {
+		digest, err := fileDigest(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+
+		cacheKey = contextVectorCacheKey(digest, source, normalized, hints, limit, compression)
+		if vectors, ok := re.contextVectors.get(cacheKey); ok {
+			_ = file.Close()
+			return rekeyContextVectors(vectors, targets, normalized), nil
+		}
+	}
+
 	res, err := re.client.send("GET", "/context-vector", data, files, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	vectors := res.(map[string]interface{})["vectors"]
+	vectors, err := makeContextVectors(res.(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheKey != "" {
+		re.contextVectors.put(cacheKey, hints, vectors)
+	}
 
-	return vectors.(map[string]interface{}), nil
+	return rekeyContextVectors(vectors, targets, normalized), nil
 }
 
 func (re *ModernMT) HandleTranslateCallback(body []byte, signature string) (Translation, error) {
@@ -461,13 +611,26 @@ func (re *ModernMT) HandleTranslateListCallback(body []byte, signature string) (
 }
 
 func (re *ModernMT) HandleTranslateListCallbackWithMetadata(body []byte, signature string, metadata interface{}) ([]Translation, error) {
-	err := re.verifyCallbackSignature(signature)
+	id, err := re.verifyCallbackSignature(body, signature)
 	if err != nil {
 		return nil, err
 	}
 
+	translations, err := parseTranslateCallback(body, metadata)
+	if err != nil {
+		// a callback that could not be handled is accepted again when delivered a second time
+		if _, ok := err.(APIError); !ok {
+			re.seenCallbacks.remove(id)
+		}
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+func parseTranslateCallback(body []byte, metadata interface{}) ([]Translation, error) {
 	var jBody map[string]interface{}
-	err = json.Unmarshal(body, &jBody)
+	err := json.Unmarshal(body, &jBody)
 	if err != nil {
 		return nil, err
 	}
@@ -506,7 +669,13 @@ func (re *ModernMT) HandleTranslateListCallbackWithMetadata(body []byte, signatu
 	}
 }
 
-func (re *ModernMT) verifyCallbackSignature(signature string) error {
+// verifies the callback and returns its replay id
+func (re *ModernMT) verifyCallbackSignature(body []byte, signature string) (string, error) {
+	var options []jwt.ParserOption
+	if re.callbackOptions != nil {
+		options = re.callbackOptions.parserOptions()
+	}
+
 	token, err := jwt.Parse(signature, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
@@ -519,13 +688,24 @@ func (re *ModernMT) verifyCallbackSignature(signature string) error {
 		}
 
 		return pk, nil
-	})
+	}, options...)
+	if err != nil {
+		return "", err
+	}
 
-	if _, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return nil
-	} else {
-		return err
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", errors.New("invalid callback signature")
+	}
+
+	if re.callbackOptions != nil {
+		err = re.validateCallbackClaims(body, claims)
+		if err != nil {
+			return "", err
+		}
 	}
+
+	return re.checkCallbackReplay(body, signature, claims)
 }
 
 func (re *ModernMT) getPublicKey() (*rsa.PublicKey, error) {
@@ -578,6 +758,64 @@ func (re *ModernMT) Qe(source string, target string, sentence string, translatio
 func (re *ModernMT) QeList(source string, target string,
 	sentences []string, translations []string) ([]QualityEstimation, error) {
 
+	if len(sentences) != len(translations) {
+		return nil, fmt.Errorf("sentences and translations must have the same length, got %d and %d",
+			len(sentences), len(translations))
+	}
+
+	source, err := re.normalizeLanguage(source)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err = re.normalizeLanguage(target)
+	if err != nil {
+		return nil, err
+	}
+
+	qes := make([]QualityEstimation, 0, len(sentences))
+
+	for begin := 0; begin < len(sentences); {
+		end := begin
+		chars := 0
+		for end < len(sentences) && end-begin < maxSegmentsPerRequest {
+			length := utf8.RuneCountInString(sentences[end]) + utf8.RuneCountInString(translations[end])
+			if end > begin && chars+length > maxCharactersPerRequest {
+				break
+			}
+			chars += length
+			end++
+		}
+
+		res, err := re.qeList(source, target, sentences[begin:end], translations[begin:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(res) != end-begin {
+			return nil, errors.New("quality estimation returned a wrong number of scores")
+		}
+
+		qes = append(qes, res...)
+		begin = end
+	}
+
+	return qes, nil
+}
+
+func (re *ModernMT) QeListPairs(source string, target string, pairs []QePair) ([]QualityEstimation, error) {
+	sentences := make([]string, len(pairs))
+	translations := make([]string, len(pairs))
+	for i, pair := range pairs {
+		sentences[i] = pair.Sentence
+		translations[i] = pair.Translation
+	}
+
+	return re.QeList(source, target, sentences, translations)
+}
+
+func (re *ModernMT) qeList(source string, target string,
+	sentences []string, translations []string) ([]QualityEstimation, error) {
+
 	data := map[string]interface{}{
 		"source":      source,
 		"target":      target,
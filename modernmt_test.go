@@ -0,0 +1,55 @@
+package modernmt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextVectorsFromFileClosedWithoutRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "document.txt")
+	if err := os.WriteFile(path, []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mmt := Create("api-key")
+	mmt.SetBaseUrl("http://127.0.0.1:0")
+	mmt.EnableContextVectorCache(10, time.Hour)
+
+	tests := []struct {
+		name    string
+		source  string
+		targets []string
+	}{
+		{"invalid source", "e", []string{"it"}},
+		{"invalid target", "en", []string{"it", "english1"}},
+	}
+
+	for _, test := range tests {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = mmt.GetContextVectorsFromFileByKeys(test.source, test.targets, file, nil, 0, ""); err == nil {
+			t.Errorf("%s: want an error", test.name)
+		}
+		if err = file.Close(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s: file was left open", test.name)
+		}
+	}
+}
+
+func TestTranslateRequestLanguages(t *testing.T) {
+	mmt := Create("api-key")
+	mmt.SetBaseUrl("http://127.0.0.1:0")
+
+	// languages are checked before the request is sent
+	_, err := mmt.TranslateWithRequest(TranslateRequest{Source: Language("en"), Target: "english1", Segments: []string{"a"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid language tag") {
+		t.Errorf("got %v, want an invalid language tag error", err)
+	}
+}
@@ -0,0 +1,295 @@
+package modernmttest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/modernmt/modernmt-go"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type FakeTranslator func(source string, target string, q []string) ([]string, error)
+
+type BatchSimulator struct {
+	Issuer string
+
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	translator FakeTranslator
+	client     *http.Client
+	pending    sync.WaitGroup
+	mutex      sync.Mutex
+	errors     []error
+	upstream   *httputil.ReverseProxy
+}
+
+func NewBatchSimulator(translator FakeTranslator) (*BatchSimulator, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	simulator := &BatchSimulator{
+		key:        key,
+		translator: translator,
+		client:     &http.Client{},
+	}
+	simulator.server = httptest.NewServer(http.HandlerFunc(simulator.serve))
+
+	return simulator, nil
+}
+
+func (re *BatchSimulator) URL() string {
+	return re.server.URL
+}
+
+func (re *BatchSimulator) PublicKey() *rsa.PublicKey {
+	return &re.key.PublicKey
+}
+
+// points the client to the simulator and makes it trust the simulator signing key. Other endpoints
+// respond with 404, so that offline tests never reach the API
+func (re *BatchSimulator) Configure(mmt *modernmt.ModernMT) error {
+	mmt.SetBaseUrl(re.server.URL)
+	mmt.SetCallbackPublicKey(&re.key.PublicKey)
+	return nil
+}
+
+// like Configure, but requests to other endpoints are forwarded to the given base URL, e.g. a local fake of the
+// rest of the API
+func (re *BatchSimulator) ConfigureWithUpstream(mmt *modernmt.ModernMT, baseUrl string) error {
+	upstream, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = upstream.Host
+	}
+
+	re.mutex.Lock()
+	re.upstream = proxy
+	re.mutex.Unlock()
+
+	return re.Configure(mmt)
+}
+
+// waits for all pending callbacks and returns the delivery errors
+func (re *BatchSimulator) Wait() []error {
+	re.pending.Wait()
+
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	errs := re.errors
+	re.errors = nil
+	return errs
+}
+
+func (re *BatchSimulator) Close() {
+	re.pending.Wait()
+	re.server.Close()
+}
+
+func (re *BatchSimulator) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.Header.Get("X-HTTP-Method-Override")
+	if method == "" {
+		method = r.Method
+	}
+
+	switch {
+	case method == "POST" && r.URL.Path == "/translate/batch":
+		re.serveBatch(w, r)
+	case method == "GET" && r.URL.Path == "/translate/batch/key":
+		re.serveKey(w)
+	default:
+		re.mutex.Lock()
+		upstream := re.upstream
+		re.mutex.Unlock()
+
+		if upstream == nil {
+			writeSimulatorResponse(w, 404, nil, "NotFoundException", "unknown endpoint "+method+" "+r.URL.Path)
+			return
+		}
+		upstream.ServeHTTP(w, r)
+	}
+}
+
+func (re *BatchSimulator) serveKey(w http.ResponseWriter) {
+	der, err := x509.MarshalPKIXPublicKey(&re.key.PublicKey)
+	if err != nil {
+		writeSimulatorResponse(w, 500, nil, "InternalServerError", err.Error())
+		return
+	}
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	writeSimulatorResponse(w, 200, map[string]interface{}{
+		"publicKey": base64.StdEncoding.EncodeToString(encoded),
+	}, "", "")
+}
+
+func (re *BatchSimulator) serveBatch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Webhook  string      `json:"webhook"`
+		Source   string      `json:"source"`
+		Target   string      `json:"target"`
+		Q        []string    `json:"q"`
+		Metadata interface{} `json:"metadata"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeSimulatorResponse(w, 400, nil, "BadRequestException", err.Error())
+		return
+	}
+	if request.Webhook == "" || request.Target == "" || len(request.Q) == 0 {
+		writeSimulatorResponse(w, 400, nil, "BadRequestException", "missing webhook, target or q")
+		return
+	}
+
+	re.pending.Add(1)
+	go func() {
+		defer re.pending.Done()
+
+		err := re.deliver(request.Webhook, request.Source, request.Target, request.Q, request.Metadata)
+		if err != nil {
+			re.mutex.Lock()
+			re.errors = append(re.errors, err)
+			re.mutex.Unlock()
+		}
+	}()
+
+	writeSimulatorResponse(w, 200, map[string]interface{}{"enqueued": true}, "", "")
+}
+
+func (re *BatchSimulator) deliver(webhook string, source string, target string, q []string,
+	metadata interface{}) error {
+
+	result := map[string]interface{}{}
+
+	translated, err := re.translator(source, target, q)
+	if err == nil && len(translated) != len(q) {
+		err = errors.New("fake translator returned a wrong number of translations")
+	}
+
+	if err != nil {
+		result["status"] = 500
+		result["error"] = map[string]interface{}{
+			"type":    "InternalServerError",
+			"message": err.Error(),
+		}
+	} else {
+		var translations []map[string]interface{}
+		for i, translation := range translated {
+			chars := utf8.RuneCountInString(q[i])
+			translations = append(translations, map[string]interface{}{
+				"translation":      translation,
+				"characters":       chars,
+				"billedCharacters": chars,
+			})
+		}
+
+		result["status"] = 200
+		result["data"] = translations
+	}
+
+	callback := map[string]interface{}{
+		"result": result,
+	}
+	if metadata != nil {
+		callback["metadata"] = metadata
+	}
+
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+
+	signature, err := re.sign(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(modernmt.CallbackSignatureHeader, signature)
+
+	res, err := re.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(res.Body)
+
+	if res.StatusCode >= 300 || res.StatusCode < 200 {
+		return errors.New("webhook responded with " + res.Status)
+	}
+
+	return nil
+}
+
+func (re *BatchSimulator) sign(body []byte) (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(body)
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"jti":      hex.EncodeToString(id),
+		"iat":      now.Unix(),
+		"exp":      now.Add(time.Hour).Unix(),
+		"bodyHash": hex.EncodeToString(digest[:]),
+	}
+	if re.Issuer != "" {
+		claims["iss"] = re.Issuer
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(re.key)
+}
+
+func writeSimulatorResponse(w http.ResponseWriter, status int, data interface{}, errorType string, message string) {
+	response := map[string]interface{}{
+		"status": status,
+	}
+
+	if errorType != "" {
+		response["error"] = map[string]interface{}{
+			"type":    errorType,
+			"message": message,
+		}
+	} else {
+		response["data"] = data
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,161 @@
+package modernmttest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/modernmt/modernmt-go"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type callback struct {
+	body      []byte
+	signature string
+}
+
+func newWebhook(t *testing.T) (*httptest.Server, func() []callback) {
+	var mutex sync.Mutex
+	var callbacks []callback
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		mutex.Lock()
+		callbacks = append(callbacks, callback{body: body, signature: r.Header.Get(modernmt.CallbackSignatureHeader)})
+		mutex.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []callback {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return callbacks
+	}
+}
+
+func newSimulator(t *testing.T, mmt *modernmt.ModernMT) *BatchSimulator {
+	simulator, err := NewBatchSimulator(func(source string, target string, q []string) ([]string, error) {
+		res := make([]string, len(q))
+		for i, s := range q {
+			res[i] = strings.ToUpper(s)
+		}
+		return res, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(simulator.Close)
+
+	if err = simulator.Configure(mmt); err != nil {
+		t.Fatal(err)
+	}
+	return simulator
+}
+
+func TestBatchCallbackRoundTrip(t *testing.T) {
+	mmt := modernmt.Create("api-key")
+	simulator := newSimulator(t, mmt)
+	webhook, callbacks := newWebhook(t)
+
+	enqueued, err := mmt.BatchTranslateList(webhook.URL, "en", "it", []string{"hello", "world"}, nil)
+	if err != nil || !enqueued {
+		t.Fatalf("enqueued = %v, err = %v", enqueued, err)
+	}
+	if errs := simulator.Wait(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	received := callbacks()
+	if len(received) != 1 {
+		t.Fatalf("got %d callbacks, want 1", len(received))
+	}
+
+	translations, err := mmt.HandleTranslateListCallback(received[0].body, received[0].signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(translations) != 2 || translations[0].Translation != "HELLO" || translations[1].Translation != "WORLD" {
+		t.Errorf("unexpected translations: %+v", translations)
+	}
+
+	_, err = mmt.HandleTranslateListCallback(received[0].body, received[0].signature)
+	if !errors.Is(err, modernmt.ErrDuplicateCallback) {
+		t.Errorf("replayed callback: got %v, want ErrDuplicateCallback", err)
+	}
+}
+
+func TestBatchCallbackTamperedBody(t *testing.T) {
+	mmt := modernmt.Create("api-key")
+	mmt.SetCallbackOptions(modernmt.CallbackOptions{BodyHashClaim: "bodyHash"})
+	simulator := newSimulator(t, mmt)
+	webhook, callbacks := newWebhook(t)
+
+	if _, err := mmt.BatchTranslate(webhook.URL, "en", "it", "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	simulator.Wait()
+
+	received := callbacks()[0]
+	tampered := []byte(strings.Replace(string(received.body), "HELLO", "HACKED", 1))
+	if _, err := mmt.HandleTranslateCallback(tampered, received.signature); err == nil {
+		t.Error("tampered callback body was accepted")
+	}
+}
+
+func TestConfigureRejectsOtherEndpoints(t *testing.T) {
+	mmt := modernmt.Create("api-key")
+	newSimulator(t, mmt)
+
+	_, err := mmt.ListSupportedLanguages()
+	var apiErr modernmt.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 404 {
+		t.Errorf("got %v, want a 404 from the simulator", err)
+	}
+}
+
+func TestConfigureWithUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": 200,
+			"data":   []string{"en", "it"},
+		})
+	}))
+	defer upstream.Close()
+
+	mmt := modernmt.Create("api-key")
+	mmt.EnableLanguageValidation(0)
+
+	simulator, err := NewBatchSimulator(func(source string, target string, q []string) ([]string, error) {
+		return q, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	if err = simulator.ConfigureWithUpstream(mmt, upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	webhook, callbacks := newWebhook(t)
+
+	if _, err := mmt.BatchTranslate(webhook.URL, "en", "it", "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	if errs := simulator.Wait(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(callbacks()) != 1 {
+		t.Errorf("got %d callbacks, want 1", len(callbacks()))
+	}
+
+	if _, err := mmt.BatchTranslate(webhook.URL, "en", "xx", "hello", nil); err == nil {
+		t.Error("unsupported target language was accepted")
+	}
+}
@@ -0,0 +1,168 @@
+package modernmt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TranslateOptionsBuilder struct {
+	options TranslateOptions
+	err     error
+}
+
+func NewTranslateOptionsBuilder() *TranslateOptionsBuilder {
+	return &TranslateOptionsBuilder{}
+}
+
+func (re *TranslateOptionsBuilder) fail(err error) *TranslateOptionsBuilder {
+	if re.err == nil {
+		re.err = err
+	}
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Priority(priority Priority) *TranslateOptionsBuilder {
+	switch priority {
+	case PriorityNormal, PriorityBackground:
+		re.options.Priority = string(priority)
+		return re
+	default:
+		return re.fail(fmt.Errorf("invalid priority: %s", priority))
+	}
+}
+
+func (re *TranslateOptionsBuilder) ProjectId(projectId string) *TranslateOptionsBuilder {
+	re.options.ProjectId = projectId
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Multiline(multiline bool) *TranslateOptionsBuilder {
+	re.options.Multiline = &multiline
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Timeout(timeout time.Duration) *TranslateOptionsBuilder {
+	if timeout <= 0 {
+		return re.fail(errors.New("timeout must be positive"))
+	}
+
+	re.options.Timeout = int(timeout.Milliseconds())
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Format(format Format) *TranslateOptionsBuilder {
+	switch format {
+	case FormatPlainText, FormatXml, FormatHtml, FormatXliff:
+		re.options.Format = string(format)
+		return re
+	default:
+		return re.fail(fmt.Errorf("invalid format: %s", format))
+	}
+}
+
+func (re *TranslateOptionsBuilder) AltTranslations(altTranslations int) *TranslateOptionsBuilder {
+	if altTranslations < 0 {
+		return re.fail(errors.New("alt translations must not be negative"))
+	}
+
+	re.options.AltTranslations = altTranslations
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Session(session string) *TranslateOptionsBuilder {
+	re.options.Session = session
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Glossaries(glossaries []int64) *TranslateOptionsBuilder {
+	re.options.Glossaries = glossaries
+	return re
+}
+
+func (re *TranslateOptionsBuilder) GlossariesByKeys(glossaries []string) *TranslateOptionsBuilder {
+	re.options.Glossaries = glossaries
+	return re
+}
+
+func (re *TranslateOptionsBuilder) IgnoreGlossaryCase(ignoreGlossaryCase bool) *TranslateOptionsBuilder {
+	re.options.IgnoreGlossaryCase = ignoreGlossaryCase
+	return re
+}
+
+func (re *TranslateOptionsBuilder) MaskProfanities(maskProfanities bool) *TranslateOptionsBuilder {
+	re.options.MaskProfanities = maskProfanities
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Metadata(metadata interface{}) *TranslateOptionsBuilder {
+	re.options.Metadata = metadata
+	return re
+}
+
+func (re *TranslateOptionsBuilder) IdempotencyKey(idempotencyKey string) *TranslateOptionsBuilder {
+	re.options.IdempotencyKey = idempotencyKey
+	return re
+}
+
+func (re *TranslateOptionsBuilder) Build() (*TranslateOptions, error) {
+	return re.build(false)
+}
+
+func (re *TranslateOptionsBuilder) BuildBatch() (*TranslateOptions, error) {
+	return re.build(true)
+}
+
+func (re *TranslateOptionsBuilder) build(batch bool) (*TranslateOptions, error) {
+	if re.err != nil {
+		return nil, re.err
+	}
+
+	options := re.options
+	err := options.validate(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// fields that do not apply to the kind of translation are rejected by the builder only, while the
+// translate methods ignore them as they always did
+func (re *TranslateOptions) validate(batch bool) error {
+	err := re.validateGlossaries()
+	if err != nil {
+		return err
+	}
+
+	if batch {
+		if re.Priority != "" {
+			return errors.New("priority is not supported by batch translation")
+		}
+		if re.Timeout != 0 {
+			return errors.New("timeout is not supported by batch translation")
+		}
+	} else {
+		if re.Metadata != nil {
+			return errors.New("metadata is only supported by batch translation")
+		}
+		if re.IdempotencyKey != "" {
+			return errors.New("idempotency key is only supported by batch translation")
+		}
+	}
+
+	return nil
+}
+
+func (re *TranslateOptions) validateGlossaries() error {
+	if re == nil || re.Glossaries == nil {
+		return nil
+	}
+
+	switch re.Glossaries.(type) {
+	case []int64, []string:
+		return nil
+	default:
+		return errors.New("glossaries must be a slice of int64 or string")
+	}
+}
@@ -0,0 +1,165 @@
+package modernmt
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+type QeStatistics struct {
+	Count         int
+	Mean          float32
+	Min           float32
+	Max           float32
+	P10           float32
+	P25           float32
+	P50           float32
+	P75           float32
+	P90           float32
+	LowScoreCount int
+}
+
+// computes aggregate statistics over the scores, counting the ones lower than lowScore
+func ComputeQeStatistics(qes []QualityEstimation, lowScore float32) QeStatistics {
+	stats := QeStatistics{Count: len(qes)}
+	if len(qes) == 0 {
+		return stats
+	}
+
+	scores := make([]float64, len(qes))
+	var total float64
+	for i, qe := range qes {
+		scores[i] = float64(qe.Score)
+		total += scores[i]
+		if qe.Score < lowScore {
+			stats.LowScoreCount++
+		}
+	}
+	sort.Float64s(scores)
+
+	stats.Mean = float32(total / float64(len(scores)))
+	stats.Min = float32(scores[0])
+	stats.Max = float32(scores[len(scores)-1])
+	stats.P10 = percentile(scores, 10)
+	stats.P25 = percentile(scores, 25)
+	stats.P50 = percentile(scores, 50)
+	stats.P75 = percentile(scores, 75)
+	stats.P90 = percentile(scores, 90)
+
+	return stats
+}
+
+// linear interpolation between the closest ranks of the sorted scores
+func percentile(sorted []float64, p float64) float32 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return float32(sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower)))
+}
+
+type ScoredTranslation struct {
+	Translation string
+	Score       float32
+}
+
+type BestTranslation struct {
+	Translation
+
+	// the highest scoring candidate and its score
+	Best  string
+	Score float32
+
+	// the primary translation followed by the alternatives
+	Candidates []ScoredTranslation
+}
+
+func (re *ModernMT) TranslateBest(source string, target string, q string, alternatives int,
+	options *TranslateOptions) (BestTranslation, error) {
+
+	res, err := re.TranslateListBest(source, target, []string{q}, alternatives, options)
+	if err != nil {
+		return BestTranslation{}, err
+	}
+
+	return res[0], nil
+}
+
+func (re *ModernMT) TranslateListBest(source string, target string, q []string, alternatives int,
+	options *TranslateOptions) ([]BestTranslation, error) {
+
+	if alternatives <= 0 {
+		return nil, errors.New("alternatives must be positive")
+	}
+
+	_options := TranslateOptions{}
+	if options != nil {
+		_options = *options
+	}
+	_options.AltTranslations = alternatives
+
+	translations, err := re.translateChunked(TranslateRequest{
+		Source:   Language(source),
+		Target:   Language(target),
+		Segments: q,
+		Options:  &_options,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// candidates are scored in one request per source language, which may differ if auto-detected
+	type candidate struct {
+		segment int
+		index   int
+	}
+
+	var languages []string
+	sentences := map[string][]string{}
+	candidates := map[string][]candidate{}
+
+	best := make([]BestTranslation, len(translations))
+	for i, translation := range translations {
+		language := source
+		if language == "" {
+			language = translation.DetectedLanguage
+		}
+		if _, ok := candidates[language]; !ok {
+			languages = append(languages, language)
+		}
+
+		best[i].Translation = translation
+		for j, text := range append([]string{translation.Translation}, translation.AltTranslations...) {
+			best[i].Candidates = append(best[i].Candidates, ScoredTranslation{Translation: text})
+			sentences[language] = append(sentences[language], q[i])
+			candidates[language] = append(candidates[language], candidate{segment: i, index: j})
+		}
+	}
+
+	for _, language := range languages {
+		var texts []string
+		for _, c := range candidates[language] {
+			texts = append(texts, best[c.segment].Candidates[c.index].Translation)
+		}
+
+		scores, err := re.QeList(language, target, sentences[language], texts)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, c := range candidates[language] {
+			best[c.segment].Candidates[c.index].Score = scores[k].Score
+		}
+	}
+
+	for i := range best {
+		for j, c := range best[i].Candidates {
+			if j == 0 || c.Score > best[i].Score {
+				best[i].Best = c.Translation
+				best[i].Score = c.Score
+			}
+		}
+	}
+
+	return best, nil
+}
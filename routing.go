@@ -0,0 +1,172 @@
+package modernmt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type QeThresholds struct {
+	// scores greater or equal to Accept are accepted, scores lower than Reject are rejected
+	Accept float32
+	Reject float32
+}
+
+func (re QeThresholds) validate() error {
+	if re.Reject > re.Accept {
+		return fmt.Errorf("reject threshold %v is greater than accept threshold %v", re.Reject, re.Accept)
+	}
+	return nil
+}
+
+type QeRouter struct {
+	mutex    sync.RWMutex
+	defaults QeThresholds
+	pairs    map[string]QeThresholds
+}
+
+func NewQeRouter(defaults QeThresholds) (*QeRouter, error) {
+	err := defaults.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &QeRouter{
+		defaults: defaults,
+		pairs:    map[string]QeThresholds{},
+	}, nil
+}
+
+func (re *QeRouter) SetThresholds(source string, target string, thresholds QeThresholds) error {
+	err := thresholds.validate()
+	if err != nil {
+		return err
+	}
+
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	re.pairs[languagePairKey(source, target)] = thresholds
+	return nil
+}
+
+// looks up the thresholds of the exact pair, then of the base languages pair, then falls back to the defaults
+func (re *QeRouter) Thresholds(source string, target string) QeThresholds {
+	re.mutex.RLock()
+	defer re.mutex.RUnlock()
+
+	if thresholds, ok := re.pairs[languagePairKey(source, target)]; ok {
+		return thresholds
+	}
+	if thresholds, ok := re.pairs[languagePairKey(languageBase(source), languageBase(target))]; ok {
+		return thresholds
+	}
+	return re.defaults
+}
+
+func languagePairKey(source string, target string) string {
+	if language, err := ParseLanguage(source); err == nil {
+		source = string(language)
+	}
+	if language, err := ParseLanguage(target); err == nil {
+		target = string(language)
+	}
+	return source + ">" + target
+}
+
+type RoutedSegment struct {
+	Index       int
+	Sentence    string
+	Translation Translation
+	Score       float32
+}
+
+type RoutingStats struct {
+	Total            int
+	Accepted         int
+	Reviewed         int
+	Rejected         int
+	Scores           QeStatistics
+	Characters       int
+	BilledCharacters int
+}
+
+type RoutedJob struct {
+	Source     string
+	Target     string
+	Thresholds QeThresholds
+	Accept     []RoutedSegment
+	Review     []RoutedSegment
+	Reject     []RoutedSegment
+	Stats      RoutingStats
+}
+
+func (re *ModernMT) TranslateAndRoute(source string, target string, segments []string, router *QeRouter,
+	options *TranslateOptions) (RoutedJob, error) {
+
+	if source == "" {
+		return RoutedJob{}, errors.New("source language is required to route translations")
+	}
+	if router == nil {
+		return RoutedJob{}, errors.New("router is required")
+	}
+
+	thresholds := router.Thresholds(source, target)
+	job := RoutedJob{
+		Source:     source,
+		Target:     target,
+		Thresholds: thresholds,
+	}
+	if len(segments) == 0 {
+		return job, nil
+	}
+
+	translations, err := re.translateChunked(TranslateRequest{
+		Source:   Language(source),
+		Target:   Language(target),
+		Segments: segments,
+		Options:  options,
+	})
+	if err != nil {
+		return RoutedJob{}, err
+	}
+
+	texts := make([]string, len(translations))
+	for i, translation := range translations {
+		texts[i] = translation.Translation
+	}
+
+	scores, err := re.QeList(source, target, segments, texts)
+	if err != nil {
+		return RoutedJob{}, err
+	}
+
+	for i, translation := range translations {
+		segment := RoutedSegment{
+			Index:       i,
+			Sentence:    segments[i],
+			Translation: translation,
+			Score:       scores[i].Score,
+		}
+
+		switch {
+		case segment.Score >= thresholds.Accept:
+			job.Accept = append(job.Accept, segment)
+		case segment.Score < thresholds.Reject:
+			job.Reject = append(job.Reject, segment)
+		default:
+			job.Review = append(job.Review, segment)
+		}
+
+		job.Stats.Characters += translation.Characters
+		job.Stats.BilledCharacters += translation.BilledCharacters
+	}
+
+	job.Stats.Total = len(translations)
+	job.Stats.Accepted = len(job.Accept)
+	job.Stats.Reviewed = len(job.Review)
+	job.Stats.Rejected = len(job.Reject)
+	job.Stats.Scores = ComputeQeStatistics(scores, thresholds.Reject)
+
+	return job, nil
+}
@@ -0,0 +1,272 @@
+package modernmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const tmxDateLayout = "20060102T150405Z"
+
+type TranslationUnit struct {
+	Tuid         string
+	Source       string
+	Target       string
+	Sentence     string
+	Translation  string
+	CreationDate time.Time
+	Props        map[string]string
+}
+
+type TmxHeader struct {
+	SourceLanguage      string
+	CreationTool        string
+	CreationToolVersion string
+	CreationDate        time.Time
+
+	// segments contain XML-like markup, to be encoded as bpt, ept, it and ph inline elements
+	InlineTags bool
+}
+
+type TmxWriter struct {
+	w          *bufio.Writer
+	inlineTags bool
+	closed     bool
+}
+
+func NewTmxWriter(w io.Writer, header TmxHeader) (*TmxWriter, error) {
+	writer := &TmxWriter{
+		w:          bufio.NewWriter(w),
+		inlineTags: header.InlineTags,
+	}
+
+	sourceLanguage := header.SourceLanguage
+	if sourceLanguage == "" {
+		sourceLanguage = "*all*"
+	}
+	creationTool := header.CreationTool
+	if creationTool == "" {
+		creationTool = "modernmt-go"
+	}
+	creationToolVersion := header.CreationToolVersion
+	if creationToolVersion == "" {
+		creationToolVersion = "1"
+	}
+
+	writer.raw("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<tmx version=\"1.4\">\n<header")
+	writer.attr("creationtool", creationTool)
+	writer.attr("creationtoolversion", creationToolVersion)
+	writer.attr("segtype", "sentence")
+	writer.attr("o-tmf", "modernmt-go")
+	writer.attr("adminlang", "en-US")
+	writer.attr("srclang", sourceLanguage)
+	writer.attr("datatype", "plaintext")
+	if !header.CreationDate.IsZero() {
+		writer.attr("creationdate", header.CreationDate.UTC().Format(tmxDateLayout))
+	}
+	writer.raw("/>\n<body>\n")
+
+	return writer, writer.w.Flush()
+}
+
+func WriteTmx(w io.Writer, header TmxHeader, units []TranslationUnit) error {
+	writer, err := NewTmxWriter(w, header)
+	if err != nil {
+		return err
+	}
+
+	for _, unit := range units {
+		err = writer.Write(unit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+func (re *TmxWriter) Write(unit TranslationUnit) error {
+	if re.closed {
+		return errors.New("tmx writer is closed")
+	}
+	if unit.Source == "" || unit.Target == "" {
+		return errors.New("translation unit source and target languages are required")
+	}
+
+	return re.writeUnit(TmxUnit{
+		Tuid:         unit.Tuid,
+		CreationDate: unit.CreationDate,
+		Props:        unit.Props,
+		Variants: []TmxVariant{
+			{Language: unit.Source, Segment: unit.Sentence, inline: re.inlineTags},
+			{Language: unit.Target, Segment: unit.Translation, inline: re.inlineTags},
+		},
+	})
+}
+
+func (re *TmxWriter) writeUnit(unit TmxUnit) error {
+	re.raw("<tu")
+	if unit.Tuid != "" {
+		re.attr("tuid", unit.Tuid)
+	}
+	if !unit.CreationDate.IsZero() {
+		re.attr("creationdate", unit.CreationDate.UTC().Format(tmxDateLayout))
+	}
+	re.raw(">\n")
+
+	keys := make([]string, 0, len(unit.Props))
+	for key := range unit.Props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		re.raw("<prop")
+		re.attr("type", key)
+		re.raw(">")
+		re.text(unit.Props[key])
+		re.raw("</prop>\n")
+	}
+
+	for _, variant := range unit.Variants {
+		re.tuv(variant)
+	}
+	re.raw("</tu>\n")
+
+	// flush each unit, so that the output can be streamed
+	return re.w.Flush()
+}
+
+func (re *TmxWriter) Close() error {
+	if re.closed {
+		return nil
+	}
+	re.closed = true
+
+	re.raw("</body>\n</tmx>\n")
+	return re.w.Flush()
+}
+
+func (re *TmxWriter) tuv(variant TmxVariant) {
+	re.raw("<tuv")
+	re.attr("xml:lang", variant.Language)
+	re.raw("><seg>")
+	switch {
+	case variant.spans != nil:
+		re.spans(variant.spans)
+	case variant.inline:
+		re.raw(encodeInlineTags(variant.Segment))
+	default:
+		re.text(variant.Segment)
+	}
+	re.raw("</seg></tuv>\n")
+}
+
+// writes inline elements back as they were read, turning unbalanced bpt and ept elements into isolated tags
+func (re *TmxWriter) spans(spans []tmxSpan) {
+	for _, span := range spans {
+		switch {
+		case span.element == "":
+			re.text(span.text)
+		case span.unbalanced && span.element == "bpt":
+			re.raw("<it pos=\"begin\">" + span.content + "</it>")
+		case span.unbalanced && span.element == "ept":
+			re.raw("<it pos=\"end\">" + span.content + "</it>")
+		default:
+			re.raw("<" + span.element + span.attrs + ">" + span.content + "</" + span.element + ">")
+		}
+	}
+}
+
+// write errors are sticky in bufio.Writer and are returned by the next Flush
+func (re *TmxWriter) raw(s string) {
+	_, _ = re.w.WriteString(s)
+}
+
+func (re *TmxWriter) text(s string) {
+	_ = xml.EscapeText(re.w, []byte(s))
+}
+
+func (re *TmxWriter) attr(name string, value string) {
+	re.raw(" " + name + "=\"")
+	re.text(value)
+	re.raw("\"")
+}
+
+var inlineTagRegexp = regexp.MustCompile(`<(/?)([A-Za-z_][\w:.-]*)(\s[^<>]*?)?(/?)>`)
+
+type inlineTag struct {
+	begin, end int
+	name       string
+	closing    bool
+	empty      bool
+	pair       int
+}
+
+// encodes markup as TMX inline elements: paired tags become bpt/ept, unpaired ones it and empty ones ph
+func encodeInlineTags(segment string) string {
+	matches := inlineTagRegexp.FindAllStringSubmatchIndex(segment, -1)
+
+	tags := make([]inlineTag, len(matches))
+	var open []int
+	for i, m := range matches {
+		tags[i] = inlineTag{
+			begin:   m[0],
+			end:     m[1],
+			name:    segment[m[4]:m[5]],
+			closing: m[3] > m[2],
+			empty:   m[9] > m[8],
+			pair:    -1,
+		}
+
+		switch {
+		case tags[i].empty:
+		case !tags[i].closing:
+			open = append(open, i)
+		default:
+			for j := len(open) - 1; j >= 0; j-- {
+				if tags[open[j]].name == tags[i].name {
+					tags[open[j]].pair = i
+					tags[i].pair = open[j]
+					open = append(open[:j], open[j+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	ids := map[int]int{}
+	last := 0
+
+	for i, tag := range tags {
+		_ = xml.EscapeText(&buf, []byte(segment[last:tag.begin]))
+		last = tag.end
+
+		var markup bytes.Buffer
+		_ = xml.EscapeText(&markup, []byte(segment[tag.begin:tag.end]))
+
+		switch {
+		case tag.empty:
+			buf.WriteString("<ph>" + markup.String() + "</ph>")
+		case tag.pair < 0 && tag.closing:
+			buf.WriteString("<it pos=\"end\">" + markup.String() + "</it>")
+		case tag.pair < 0:
+			buf.WriteString("<it pos=\"begin\">" + markup.String() + "</it>")
+		case !tag.closing:
+			ids[i] = len(ids) + 1
+			buf.WriteString("<bpt i=\"" + strconv.Itoa(ids[i]) + "\">" + markup.String() + "</bpt>")
+		default:
+			buf.WriteString("<ept i=\"" + strconv.Itoa(ids[tag.pair]) + "\">" + markup.String() + "</ept>")
+		}
+	}
+	_ = xml.EscapeText(&buf, []byte(segment[last:]))
+
+	return buf.String()
+}
@@ -0,0 +1,76 @@
+package modernmt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeInlineTags(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    string
+	}{
+		{"plain text", "plain text"},
+		{"a & b < c", "a &amp; b &lt; c"},
+		{"a <b>bold</b> c", `a <bpt i="1">&lt;b&gt;</bpt>bold<ept i="1">&lt;/b&gt;</ept> c`},
+		{"x<br/>y", "x<ph>&lt;br/&gt;</ph>y"},
+		{"</i>open", `<it pos="end">&lt;/i&gt;</it>open`},
+		{"<i>dangling", `<it pos="begin">&lt;i&gt;</it>dangling`},
+		{`<a href="x">l</a>`, `<bpt i="1">&lt;a href=&#34;x&#34;&gt;</bpt>l<ept i="1">&lt;/a&gt;</ept>`},
+		{"<b><i>x</i></b>",
+			`<bpt i="1">&lt;b&gt;</bpt><bpt i="2">&lt;i&gt;</bpt>x<ept i="2">&lt;/i&gt;</ept><ept i="1">&lt;/b&gt;</ept>`},
+	}
+
+	for _, test := range tests {
+		if got := encodeInlineTags(test.segment); got != test.want {
+			t.Errorf("encodeInlineTags(%q) =\n%s\nwant\n%s", test.segment, got, test.want)
+		}
+	}
+}
+
+func TestTmxWriter(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTmx(&buf, TmxHeader{SourceLanguage: "en"}, []TranslationUnit{{
+		Tuid:         "1",
+		Source:       "en",
+		Target:       "it",
+		Sentence:     "a <b> & c",
+		Translation:  "b",
+		CreationDate: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Props:        map[string]string{"z": "2", "a": "1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`srclang="en"`,
+		`<tu tuid="1" creationdate="20240102T030405Z">`,
+		"<prop type=\"a\">1</prop>\n<prop type=\"z\">2</prop>",
+		`<tuv xml:lang="en"><seg>a &lt;b&gt; &amp; c</seg></tuv>`,
+		`<tuv xml:lang="it"><seg>b</seg></tuv>`,
+		"</body>\n</tmx>\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTmxWriterRejectsMissingLanguages(t *testing.T) {
+	writer, err := NewTmxWriter(&bytes.Buffer{}, TmxHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writer.Write(TranslationUnit{Source: "en", Sentence: "a", Translation: "b"}); err == nil {
+		t.Error("unit without target language was accepted")
+	}
+
+	_ = writer.Close()
+	if err = writer.Write(TranslationUnit{Source: "en", Target: "it", Sentence: "a", Translation: "b"}); err == nil {
+		t.Error("write after close was accepted")
+	}
+}
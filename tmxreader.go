@@ -0,0 +1,607 @@
+package modernmt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type TmxVariant struct {
+	Line     int
+	Language string
+	Segment  string
+
+	// Segment is markup to be encoded as inline elements, set by TmxWriter.Write
+	inline bool
+	// the text and inline elements of a segment read from a TMX file, written back as they were
+	spans []tmxSpan
+	// bpt/ept ids that have no counterpart in the segment
+	unbalanced []string
+}
+
+// a run of text, or an inline element with its attributes and content already encoded as XML
+type tmxSpan struct {
+	text       string
+	element    string
+	attrs      string
+	content    string
+	unbalanced bool
+}
+
+type TmxUnit struct {
+	Line         int
+	Tuid         string
+	CreationDate time.Time
+	Props        map[string]string
+	Variants     []TmxVariant
+}
+
+// pairs the variant in the source language with each of the other variants
+func (re TmxUnit) TranslationUnits(sourceLanguage string) []TranslationUnit {
+	var source *TmxVariant
+	for i := range re.Variants {
+		if strings.EqualFold(re.Variants[i].Language, sourceLanguage) || sourceLanguage == "*all*" {
+			source = &re.Variants[i]
+			break
+		}
+	}
+	if source == nil {
+		return nil
+	}
+
+	var units []TranslationUnit
+	for i := range re.Variants {
+		variant := &re.Variants[i]
+		if variant == source {
+			continue
+		}
+
+		units = append(units, TranslationUnit{
+			Tuid:         re.Tuid,
+			Source:       source.Language,
+			Target:       variant.Language,
+			Sentence:     source.Segment,
+			Translation:  variant.Segment,
+			CreationDate: re.CreationDate,
+			Props:        re.Props,
+		})
+	}
+
+	return units
+}
+
+type TmxProblem struct {
+	Line    int
+	Tuid    string
+	Message string
+}
+
+func (re TmxProblem) String() string {
+	if re.Tuid != "" {
+		return fmt.Sprintf("line %d (tuid %s): %s", re.Line, re.Tuid, re.Message)
+	}
+	return fmt.Sprintf("line %d: %s", re.Line, re.Message)
+}
+
+type TmxReport struct {
+	SourceLanguage string
+	Units          int
+	Languages      []string
+	Problems       []TmxProblem
+
+	// units dropped or rewritten by CleanTmx
+	Dropped int
+	Fixed   int
+}
+
+func (re TmxReport) Valid() bool {
+	return len(re.Problems) == 0
+}
+
+// keeps track of line breaks, so that decoder offsets can be turned into line numbers
+type lineCounter struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+	line     int
+}
+
+func (re *lineCounter) Read(p []byte) (int, error) {
+	n, err := re.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			re.newlines = append(re.newlines, re.offset+int64(i))
+		}
+	}
+	re.offset += int64(n)
+	return n, err
+}
+
+// offsets must be requested in increasing order
+func (re *lineCounter) lineAt(offset int64) int {
+	for len(re.newlines) > 0 && re.newlines[0] < offset {
+		re.newlines = re.newlines[1:]
+		re.line++
+	}
+	return re.line + 1
+}
+
+type TmxReader struct {
+	decoder  *xml.Decoder
+	lines    *lineCounter
+	header   TmxHeader
+	problems []TmxProblem
+}
+
+func NewTmxReader(r io.Reader) (*TmxReader, error) {
+	lines := &lineCounter{r: r}
+	decoder := xml.NewDecoder(lines)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "us-ascii") {
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported encoding %s, TMX files must be UTF-8", charset)
+	}
+
+	reader := &TmxReader{
+		decoder: decoder,
+		lines:   lines,
+	}
+
+	// reads up to the beginning of the body
+	for {
+		token, err := reader.token()
+		if err != nil {
+			return nil, err
+		}
+
+		if el, ok := token.(xml.StartElement); ok {
+			switch el.Name.Local {
+			case "header":
+				reader.header.SourceLanguage = attrValue(el, "srclang")
+				reader.header.CreationTool = attrValue(el, "creationtool")
+				reader.header.CreationToolVersion = attrValue(el, "creationtoolversion")
+				if date := attrValue(el, "creationdate"); date != "" {
+					reader.header.CreationDate, _ = time.Parse(tmxDateLayout, date)
+				}
+			case "body":
+				if reader.header.SourceLanguage == "" {
+					reader.problem("", "missing header or header srclang")
+				}
+				return reader, nil
+			}
+		}
+	}
+}
+
+func (re *TmxReader) Header() TmxHeader {
+	return re.header
+}
+
+func (re *TmxReader) line() int {
+	return re.lines.lineAt(re.decoder.InputOffset())
+}
+
+func (re *TmxReader) problem(tuid string, message string) {
+	re.problems = append(re.problems, TmxProblem{Line: re.line(), Tuid: tuid, Message: message})
+}
+
+// syntax errors are returned as TmxSyntaxError values, reporting the line where they occurred
+func (re *TmxReader) token() (xml.Token, error) {
+	token, err := re.decoder.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		var syntaxError *xml.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return nil, TmxSyntaxError{Line: syntaxError.Line, Message: syntaxError.Msg}
+		}
+		return nil, TmxSyntaxError{Line: re.line(), Message: err.Error()}
+	}
+	return token, nil
+}
+
+type TmxSyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (re TmxSyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", re.Line, re.Message)
+}
+
+// returns the next translation unit, or io.EOF at the end of the body
+func (re *TmxReader) Next() (TmxUnit, error) {
+	for {
+		line := re.line()
+		token, err := re.token()
+		if err != nil {
+			return TmxUnit{}, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Local == "tu" {
+				return re.readUnit(el, line)
+			}
+			err = re.decoder.Skip()
+			if err != nil {
+				return TmxUnit{}, err
+			}
+		case xml.EndElement:
+			if el.Name.Local == "body" {
+				return TmxUnit{}, io.EOF
+			}
+		}
+	}
+}
+
+func (re *TmxReader) readUnit(start xml.StartElement, line int) (TmxUnit, error) {
+	unit := TmxUnit{
+		Line: line,
+		Tuid: attrValue(start, "tuid"),
+	}
+	if date := attrValue(start, "creationdate"); date != "" {
+		unit.CreationDate, _ = time.Parse(tmxDateLayout, date)
+	}
+
+	for {
+		line := re.line()
+		token, err := re.token()
+		if err != nil {
+			return TmxUnit{}, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "prop":
+				text, err := re.readText()
+				if err != nil {
+					return TmxUnit{}, err
+				}
+				if unit.Props == nil {
+					unit.Props = map[string]string{}
+				}
+				unit.Props[attrValue(el, "type")] = text
+			case "tuv":
+				variant, err := re.readVariant(el, line)
+				if err != nil {
+					return TmxUnit{}, err
+				}
+				unit.Variants = append(unit.Variants, variant)
+			default:
+				err = re.decoder.Skip()
+				if err != nil {
+					return TmxUnit{}, err
+				}
+			}
+		case xml.EndElement:
+			return unit, nil
+		}
+	}
+}
+
+func (re *TmxReader) readVariant(start xml.StartElement, line int) (TmxVariant, error) {
+	variant := TmxVariant{
+		Line:     line,
+		Language: attrValue(start, "lang"),
+	}
+
+	for {
+		token, err := re.token()
+		if err != nil {
+			return TmxVariant{}, err
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			if el.Name.Local == "seg" {
+				err = re.readSegment(&variant)
+			} else {
+				err = re.decoder.Skip()
+			}
+			if err != nil {
+				return TmxVariant{}, err
+			}
+		case xml.EndElement:
+			return variant, nil
+		}
+	}
+}
+
+// restores inline elements as the markup they contain, checking that bpt and ept elements are balanced. The
+// elements are also kept as they were, so that CleanTmx writes them back without re-encoding the text around them
+func (re *TmxReader) readSegment(variant *TmxVariant) error {
+	var segment bytes.Buffer
+	var spans []tmxSpan
+	var content bytes.Buffer
+	open := map[string]int{}
+	depth := 0
+	inline := false
+
+	for {
+		token, err := re.token()
+		if err != nil {
+			return err
+		}
+
+		switch el := token.(type) {
+		case xml.CharData:
+			segment.Write(el)
+			if depth == 0 {
+				spans = append(spans, tmxSpan{text: string(el)})
+			} else {
+				_ = xml.EscapeText(&content, el)
+			}
+		case xml.StartElement:
+			inline = true
+			depth++
+			if depth > 1 {
+				content.WriteString("<" + xmlName(el.Name) + encodeXmlAttrs(el.Attr) + ">")
+				continue
+			}
+
+			span := tmxSpan{element: el.Name.Local, attrs: encodeXmlAttrs(el.Attr)}
+			switch el.Name.Local {
+			case "bpt":
+				open[attrValue(el, "i")] = len(spans)
+			case "ept":
+				i := attrValue(el, "i")
+				if _, ok := open[i]; ok {
+					delete(open, i)
+				} else {
+					span.unbalanced = true
+					variant.unbalanced = append(variant.unbalanced, i)
+				}
+			}
+			spans = append(spans, span)
+			content.Reset()
+		case xml.EndElement:
+			if depth == 0 {
+				for i, span := range open {
+					spans[span].unbalanced = true
+					variant.unbalanced = append(variant.unbalanced, i)
+				}
+				sort.Strings(variant.unbalanced)
+
+				variant.Segment = segment.String()
+				if inline {
+					variant.spans = spans
+				}
+				return nil
+			}
+
+			depth--
+			if depth > 0 {
+				content.WriteString("</" + xmlName(el.Name) + ">")
+			} else {
+				spans[len(spans)-1].content = content.String()
+			}
+		}
+	}
+}
+
+func (re *TmxReader) readText() (string, error) {
+	var text bytes.Buffer
+	for {
+		token, err := re.token()
+		if err != nil {
+			return "", err
+		}
+
+		switch el := token.(type) {
+		case xml.CharData:
+			text.Write(el)
+		case xml.StartElement:
+			err = re.decoder.Skip()
+			if err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			return text.String(), nil
+		}
+	}
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "xml" || name.Space == "http://www.w3.org/XML/1998/namespace" {
+		return "xml:" + name.Local
+	}
+	return name.Local
+}
+
+func encodeXmlAttrs(attrs []xml.Attr) string {
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		buf.WriteString(" " + xmlName(attr.Name) + "=\"")
+		_ = xml.EscapeText(&buf, []byte(attr.Value))
+		buf.WriteString("\"")
+	}
+	return buf.String()
+}
+
+// matches both xml:lang and the lang attribute of TMX 1.1
+func attrValue(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func ValidateTmx(r io.Reader) (TmxReport, error) {
+	return processTmx(r, nil)
+}
+
+// re-emits the units that can be fixed, normalizing language tags and inline elements, and drops the others
+func CleanTmx(r io.Reader, w io.Writer) (TmxReport, error) {
+	return processTmx(r, w)
+}
+
+func processTmx(r io.Reader, w io.Writer) (TmxReport, error) {
+	var report TmxReport
+
+	reader, err := NewTmxReader(r)
+	if err != nil {
+		return reportSyntaxError(report, err)
+	}
+
+	header := reader.Header()
+	report.SourceLanguage = header.SourceLanguage
+	report.Problems = append(report.Problems, reader.problems...)
+
+	sourceLanguage := header.SourceLanguage
+	if sourceLanguage != "" && sourceLanguage != "*all*" {
+		if language, err := ParseLanguage(sourceLanguage); err != nil {
+			report.Problems = append(report.Problems, TmxProblem{Line: 1, Message: "invalid srclang: " + sourceLanguage})
+		} else {
+			sourceLanguage = string(language)
+		}
+	}
+
+	var writer *TmxWriter
+	if w != nil {
+		header.SourceLanguage = sourceLanguage
+		writer, err = NewTmxWriter(w, header)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	languages := map[string]bool{}
+	tuids := map[string]int{}
+
+	for {
+		unit, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return reportSyntaxError(report, err)
+		}
+
+		report.Units++
+		problems, drop, fixed := checkTmxUnit(&unit, sourceLanguage, tuids)
+		report.Problems = append(report.Problems, problems...)
+
+		for _, variant := range unit.Variants {
+			if variant.Language != "" {
+				languages[variant.Language] = true
+			}
+		}
+
+		if writer == nil {
+			continue
+		}
+
+		if drop {
+			report.Dropped++
+			continue
+		}
+		if fixed {
+			report.Fixed++
+		}
+
+		err = writer.writeUnit(unit)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for language := range languages {
+		report.Languages = append(report.Languages, language)
+	}
+	sort.Strings(report.Languages)
+
+	if writer != nil {
+		return report, writer.Close()
+	}
+
+	return report, nil
+}
+
+func reportSyntaxError(report TmxReport, err error) (TmxReport, error) {
+	if syntaxError, ok := err.(TmxSyntaxError); ok {
+		report.Problems = append(report.Problems, TmxProblem{Line: syntaxError.Line, Message: syntaxError.Message})
+		return report, nil
+	}
+	var xmlError *xml.SyntaxError
+	if errors.As(err, &xmlError) {
+		report.Problems = append(report.Problems, TmxProblem{Line: xmlError.Line, Message: xmlError.Msg})
+		return report, nil
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		report.Problems = append(report.Problems, TmxProblem{Message: "unexpected end of file"})
+		return report, nil
+	}
+	return report, err
+}
+
+// reports the problems of a unit and normalizes it in place; drop is set when it cannot be fixed
+func checkTmxUnit(unit *TmxUnit, sourceLanguage string, tuids map[string]int) ([]TmxProblem, bool, bool) {
+	var problems []TmxProblem
+	drop, fixed := false, false
+
+	report := func(line int, message string) {
+		problems = append(problems, TmxProblem{Line: line, Tuid: unit.Tuid, Message: message})
+	}
+
+	if unit.Tuid != "" {
+		if line, ok := tuids[unit.Tuid]; ok {
+			report(unit.Line, fmt.Sprintf("duplicate tuid, first seen at line %d", line))
+			drop = true
+		} else {
+			tuids[unit.Tuid] = unit.Line
+		}
+	}
+
+	hasSource := false
+	for i := range unit.Variants {
+		variant := &unit.Variants[i]
+
+		if variant.Language == "" {
+			report(variant.Line, "missing tuv language")
+			drop = true
+		} else if language, err := ParseLanguage(variant.Language); err != nil {
+			report(variant.Line, "invalid tuv language: "+variant.Language)
+			drop = true
+		} else if string(language) != variant.Language {
+			variant.Language = string(language)
+			fixed = true
+		}
+
+		if variant.Language == sourceLanguage {
+			hasSource = true
+		}
+
+		if strings.TrimSpace(variant.Segment) == "" {
+			report(variant.Line, "empty segment")
+			drop = true
+		}
+
+		if len(variant.unbalanced) > 0 {
+			report(variant.Line, "unbalanced inline tags: "+strings.Join(variant.unbalanced, ", "))
+			fixed = true
+		}
+	}
+
+	if len(unit.Variants) < 2 {
+		report(unit.Line, fmt.Sprintf("translation unit has %d variants, at least 2 required", len(unit.Variants)))
+		drop = true
+	}
+
+	if sourceLanguage != "" && sourceLanguage != "*all*" && !hasSource {
+		report(unit.Line, "no variant in the source language "+sourceLanguage)
+		drop = true
+	}
+
+	return problems, drop, fixed
+}
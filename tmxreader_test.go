@@ -0,0 +1,301 @@
+package modernmt
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tmxPrologue = `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+<header srclang="en" datatype="plaintext"/>
+<body>
+`
+
+func TestCleanTmxRoundTrip(t *testing.T) {
+	units := []TranslationUnit{
+		{Tuid: "1", Source: "en_us", Target: "it_it", Sentence: "Hello <b>world</b>", Translation: "Ciao <b>mondo</b>"},
+		{Tuid: "2", Source: "en_us", Target: "it_it", Sentence: "a & b", Translation: "a e b",
+			Props: map[string]string{"x-domain": "legal"}},
+		{Source: "en_us", Target: "it_it", Sentence: "line<br/>break", Translation: "a capo<br/>"},
+	}
+
+	var written bytes.Buffer
+	err := WriteTmx(&written, TmxHeader{SourceLanguage: "en_us", InlineTags: true}, units)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cleaned bytes.Buffer
+	report, err := CleanTmx(bytes.NewReader(written.Bytes()), &cleaned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Valid() || report.Units != 3 || report.Dropped != 0 || report.Fixed != 3 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if !reflect.DeepEqual(report.Languages, []string{"en-US", "it-IT"}) {
+		t.Errorf("languages = %v, want normalized tags", report.Languages)
+	}
+
+	reader, err := NewTmxReader(bytes.NewReader(cleaned.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reader.Header().SourceLanguage; got != "en-US" {
+		t.Errorf("cleaned srclang = %q, want en-US", got)
+	}
+
+	for i := 0; ; i++ {
+		unit, err := reader.Next()
+		if err == io.EOF {
+			if i != len(units) {
+				t.Errorf("read %d units, want %d", i, len(units))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := unit.TranslationUnits("en-US")
+		if len(got) != 1 {
+			t.Fatalf("unit %d: got %d translation units, want 1", i, len(got))
+		}
+
+		want := units[i]
+		want.Source, want.Target = "en-US", "it-IT"
+		if !reflect.DeepEqual(got[0], want) {
+			t.Errorf("unit %d = %+v, want %+v", i, got[0], want)
+		}
+	}
+
+	// a clean file is left unchanged by a second pass
+	var again bytes.Buffer
+	report, err = CleanTmx(bytes.NewReader(cleaned.Bytes()), &again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Fixed != 0 || again.String() != cleaned.String() {
+		t.Errorf("second pass fixed %d units:\n%s", report.Fixed, again.String())
+	}
+}
+
+func TestValidateTmxProblems(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		lines []int
+		want  []string
+	}{
+		{
+			name: "valid",
+			body: `<tu tuid="1"><tuv xml:lang="en"><seg>a</seg></tuv><tuv xml:lang="it"><seg>b</seg></tuv></tu>`,
+		},
+		{
+			name: "duplicate tuid",
+			body: `<tu tuid="1"><tuv xml:lang="en"><seg>a</seg></tuv><tuv xml:lang="it"><seg>b</seg></tuv></tu>
+<tu tuid="1"><tuv xml:lang="en"><seg>c</seg></tuv><tuv xml:lang="it"><seg>d</seg></tuv></tu>`,
+			lines: []int{6},
+			want:  []string{"duplicate tuid, first seen at line 5"},
+		},
+		{
+			name: "unbalanced bpt",
+			body: `<tu>
+<tuv xml:lang="en"><seg><bpt i="1">&lt;b&gt;</bpt>a</seg></tuv>
+<tuv xml:lang="it"><seg>b<ept i="2">&lt;/b&gt;</ept></seg></tuv>
+</tu>`,
+			lines: []int{6, 7},
+			want:  []string{"unbalanced inline tags: 1", "unbalanced inline tags: 2"},
+		},
+		{
+			name: "invalid language",
+			body: `<tu>
+<tuv xml:lang="en"><seg>a</seg></tuv>
+<tuv xml:lang="it_1"><seg>b</seg></tuv>
+</tu>`,
+			lines: []int{7},
+			want:  []string{"invalid tuv language: it_1"},
+		},
+		{
+			name: "empty segment and single variant",
+			body: `<tu>
+<tuv xml:lang="en"><seg> </seg></tuv>
+</tu>`,
+			lines: []int{6, 5},
+			want:  []string{"empty segment", "translation unit has 1 variants, at least 2 required"},
+		},
+		{
+			name:  "missing source",
+			body:  `<tu><tuv xml:lang="de"><seg>a</seg></tuv><tuv xml:lang="it"><seg>b</seg></tuv></tu>`,
+			lines: []int{5},
+			want:  []string{"no variant in the source language en"},
+		},
+		{
+			name:  "syntax error",
+			body:  "\n\n<tu><tuv xml:lang=\"en\"><seg>a</tuv></tu>",
+			lines: []int{7},
+			want:  []string{"element <seg> closed by </tuv>"},
+		},
+	}
+
+	for _, test := range tests {
+		report, err := ValidateTmx(strings.NewReader(tmxPrologue + test.body + "\n</body>\n</tmx>\n"))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if len(report.Problems) != len(test.want) {
+			t.Errorf("%s: got problems %v, want %v", test.name, report.Problems, test.want)
+			continue
+		}
+		for i, problem := range report.Problems {
+			if problem.Message != test.want[i] || problem.Line != test.lines[i] {
+				t.Errorf("%s: problem %d = %q at line %d, want %q at line %d", test.name, i,
+					problem.Message, problem.Line, test.want[i], test.lines[i])
+			}
+		}
+	}
+}
+
+func TestCleanTmxFixesAndDrops(t *testing.T) {
+	body := `<tu tuid="1"><tuv xml:lang="EN"><seg>a</seg></tuv><tuv xml:lang="pt_br"><seg>b</seg></tuv></tu>
+<tu tuid="1"><tuv xml:lang="en"><seg>c</seg></tuv><tuv xml:lang="it"><seg>d</seg></tuv></tu>
+<tu tuid="2"><tuv xml:lang="en"><seg><bpt i="1">&lt;b&gt;</bpt>e</seg></tuv><tuv xml:lang="it"><seg>f</seg></tuv></tu>
+`
+
+	var cleaned bytes.Buffer
+	report, err := CleanTmx(strings.NewReader(tmxPrologue+body+"</body>\n</tmx>\n"), &cleaned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Units != 3 || report.Dropped != 1 || report.Fixed != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	for _, want := range []string{
+		`<tuv xml:lang="en"><seg>a</seg></tuv>`,
+		`<tuv xml:lang="pt-BR"><seg>b</seg></tuv>`,
+		`<seg><it pos="begin">&lt;b&gt;</it>e</seg>`,
+	} {
+		if !strings.Contains(cleaned.String(), want) {
+			t.Errorf("cleaned output does not contain %q:\n%s", want, cleaned.String())
+		}
+	}
+	if strings.Contains(cleaned.String(), "<seg>c</seg>") {
+		t.Errorf("unit with duplicate tuid was not dropped:\n%s", cleaned.String())
+	}
+}
+
+func TestTmxReaderLineNumbers(t *testing.T) {
+	tmx := tmxPrologue + `<tu tuid="a">
+<prop type="note">x</prop>
+<tuv xml:lang="en"><seg>a</seg></tuv>
+<tuv xml:lang="it"><seg>b</seg></tuv>
+</tu>
+
+<tu tuid="b"><tuv xml:lang="en"><seg>c</seg></tuv><tuv xml:lang="it"><seg>d</seg></tuv></tu>
+</body>
+</tmx>
+`
+
+	reader, err := NewTmxReader(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		unit     int
+		variants []int
+	}{
+		{5, []int{7, 8}},
+		{11, []int{11, 11}},
+	}
+
+	for _, w := range want {
+		unit, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if unit.Line != w.unit {
+			t.Errorf("unit %s at line %d, want %d", unit.Tuid, unit.Line, w.unit)
+		}
+		for i, variant := range unit.Variants {
+			if variant.Line != w.variants[i] {
+				t.Errorf("unit %s variant %d at line %d, want %d", unit.Tuid, i, variant.Line, w.variants[i])
+			}
+		}
+	}
+
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("got %v at the end of the body, want io.EOF", err)
+	}
+}
+
+func TestCleanTmxKeepsInlineElements(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment string
+		want    string
+		fixed   int
+	}{
+		{
+			name:    "escaped markup next to an inline element",
+			segment: `type &lt;name&gt; then <ph>&lt;br/&gt;</ph>`,
+			want:    `type &lt;name&gt; then <ph>&lt;br/&gt;</ph>`,
+		},
+		{
+			name:    "attributes and nested elements",
+			segment: `<bpt i="1" type="bold">&lt;b&gt;</bpt>a &amp; b<ept i="1">&lt;/b&gt;</ept><ph x="2">{<sub>c</sub>}</ph>`,
+			want:    `<bpt i="1" type="bold">&lt;b&gt;</bpt>a &amp; b<ept i="1">&lt;/b&gt;</ept><ph x="2">{<sub>c</sub>}</ph>`,
+		},
+		{
+			name:    "unbalanced bpt next to escaped markup",
+			segment: `&lt;i&gt; <bpt i="1">&lt;b&gt;</bpt>x`,
+			want:    `&lt;i&gt; <it pos="begin">&lt;b&gt;</it>x`,
+			fixed:   1,
+		},
+	}
+
+	for _, test := range tests {
+		tmx := tmxPrologue + `<tu><tuv xml:lang="en"><seg>` + test.segment +
+			`</seg></tuv><tuv xml:lang="it"><seg>b</seg></tuv></tu>` + "\n</body>\n</tmx>\n"
+
+		var cleaned bytes.Buffer
+		report, err := CleanTmx(strings.NewReader(tmx), &cleaned)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if report.Fixed != test.fixed || report.Dropped != 0 {
+			t.Errorf("%s: unexpected report %+v", test.name, report)
+		}
+		if want := `<seg>` + test.want + `</seg>`; !strings.Contains(cleaned.String(), want) {
+			t.Errorf("%s: cleaned output does not contain %q:\n%s", test.name, want, cleaned.String())
+		}
+	}
+}
+
+func TestTmxReaderRestoresInlineMarkup(t *testing.T) {
+	tmx := tmxPrologue + `<tu><tuv xml:lang="en"><seg>type &lt;name&gt; then <ph>&lt;br/&gt;</ph></seg></tuv>` +
+		`<tuv xml:lang="it"><seg>b</seg></tuv></tu>` + "\n</body>\n</tmx>\n"
+
+	reader, err := NewTmxReader(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := unit.Variants[0].Segment; got != "type <name> then <br/>" {
+		t.Errorf("segment = %q", got)
+	}
+}
@@ -0,0 +1,156 @@
+package modernmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+type UpsertAction string
+
+const (
+	UpsertAdded    UpsertAction = "added"
+	UpsertReplaced UpsertAction = "replaced"
+	UpsertSkipped  UpsertAction = "skipped"
+)
+
+// stable tuid of a sentence in a language pair, so that its translation can be replaced later
+func ContentTuid(source string, target string, sentence string) string {
+	hash := sha256.New()
+	for _, s := range []string{canonicalLanguage(source), canonicalLanguage(target), sentence} {
+		hash.Write([]byte(s))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+func canonicalLanguage(tag string) string {
+	language, err := ParseLanguage(tag)
+	if err != nil {
+		return tag
+	}
+	return language.String()
+}
+
+// local record of the translations sent to each memory, indexed by tuid
+type TuidIndex struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[string]map[string]string
+}
+
+// loads the index persisted at path, or returns an empty one if the file does not exist yet
+func LoadTuidIndex(path string) (*TuidIndex, error) {
+	index := &TuidIndex{
+		path:    path,
+		entries: map[string]map[string]string{},
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &index.entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tuid index file %s: %v", path, err)
+	}
+	if index.entries == nil {
+		index.entries = map[string]map[string]string{}
+	}
+
+	return index, nil
+}
+
+func (re *TuidIndex) Save() error {
+	re.mutex.Lock()
+	content, err := json.MarshalIndent(re.entries, "", "  ")
+	re.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(re.path, content)
+}
+
+func (re *TuidIndex) Len(memory string) int {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	return len(re.entries[memory])
+}
+
+// drops all the entries of a memory, e.g. after it has been deleted
+func (re *TuidIndex) Forget(memory string) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	delete(re.entries, memory)
+}
+
+func (re *TuidIndex) lookup(memory string, tuid string) (string, bool) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	hash, ok := re.entries[memory][tuid]
+	return hash, ok
+}
+
+func (re *TuidIndex) record(memory string, tuid string, hash string) {
+	re.mutex.Lock()
+	defer re.mutex.Unlock()
+
+	if re.entries[memory] == nil {
+		re.entries[memory] = map[string]string{}
+	}
+	re.entries[memory][tuid] = hash
+}
+
+func translationHash(translation string) string {
+	hash := sha256.Sum256([]byte(translation))
+	return hex.EncodeToString(hash[:])
+}
+
+func (re *memoryServices) Upsert(index *TuidIndex, id int64, source string, target string, sentence string,
+	translation string) (ImportJob, UpsertAction, error) {
+	_id := strconv.FormatInt(id, 10)
+	return re.UpsertByKey(index, _id, source, target, sentence, translation)
+}
+
+// adds the unit with its content tuid, replaces it if the translation changed since it was last sent,
+// or skips it if it is unchanged. The index is updated in memory only: call Save to persist it
+func (re *memoryServices) UpsertByKey(index *TuidIndex, id string, source string, target string, sentence string,
+	translation string) (ImportJob, UpsertAction, error) {
+
+	tuid := ContentTuid(source, target, sentence)
+	hash := translationHash(translation)
+
+	previous, known := index.lookup(id, tuid)
+	if known && previous == hash {
+		return ImportJob{}, UpsertSkipped, nil
+	}
+
+	var job ImportJob
+	var err error
+	action := UpsertAdded
+	if known {
+		action = UpsertReplaced
+		job, err = re.ReplaceByKey(id, tuid, source, target, sentence, translation)
+	} else {
+		job, err = re.AddByKey(id, source, target, sentence, translation, tuid)
+	}
+	if err != nil {
+		return ImportJob{}, "", err
+	}
+
+	index.record(id, tuid, hash)
+	return job, action, nil
+}